@@ -1,13 +1,29 @@
 package main
 
 import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/vladopadikk/go-chat/internal/account"
+	"github.com/vladopadikk/go-chat/internal/admin"
+	"github.com/vladopadikk/go-chat/internal/apikey"
 	"github.com/vladopadikk/go-chat/internal/auth"
+	"github.com/vladopadikk/go-chat/internal/block"
 	"github.com/vladopadikk/go-chat/internal/chat"
 	"github.com/vladopadikk/go-chat/internal/config"
+	"github.com/vladopadikk/go-chat/internal/contact"
 	"github.com/vladopadikk/go-chat/internal/database"
+	"github.com/vladopadikk/go-chat/internal/mail"
 	"github.com/vladopadikk/go-chat/internal/messages"
+	"github.com/vladopadikk/go-chat/internal/oidc"
+	"github.com/vladopadikk/go-chat/internal/password"
+	"github.com/vladopadikk/go-chat/internal/presence"
+	"github.com/vladopadikk/go-chat/internal/session"
 	"github.com/vladopadikk/go-chat/internal/user"
+	"github.com/vladopadikk/go-chat/internal/validation"
 	"github.com/vladopadikk/go-chat/internal/ws"
 )
 
@@ -17,40 +33,148 @@ func main() {
 	db := database.Connect(cfg)
 	defer db.Close()
 
+	mailer, err := mail.New(cfg)
+	if err != nil {
+		log.Fatalf("failed to configure mailer: %v", err)
+	}
+
+	hasher, err := password.NewHasher(cfg)
+	if err != nil {
+		log.Fatalf("failed to configure password hashing: %v", err)
+	}
+
+	policy, err := password.NewPolicy(cfg)
+	if err != nil {
+		log.Fatalf("failed to configure password policy: %v", err)
+	}
+
+	if err := validation.Register(); err != nil {
+		log.Fatalf("failed to register validators: %v", err)
+	}
+
 	router := gin.Default()
+	// ClientIP feeds the login throttle, so forwarded headers are only
+	// believed from configured proxies.
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	userRepo := user.NewRepository(db)
-	userService := user.NewService(userRepo)
+	userService := user.NewService(userRepo, hasher, policy, mailer, cfg)
 	userHandler := user.NewHandler(userService)
 
+	sessionRepo := session.NewRepository(db)
+	sessionService := session.NewService(sessionRepo)
+	sessionHandler := session.NewHandler(sessionService)
+
+	apiKeyRepo := apikey.NewRepository(db)
+	apiKeyService := apikey.NewService(apiKeyRepo)
+	apiKeyHandler := apikey.NewHandler(apiKeyService)
+
 	authRepo := auth.NewRepository(db)
-	authService := auth.NewService(authRepo, cfg)
+
+	keys := auth.NewKeyManager(authRepo, cfg)
+	if err := keys.Load(context.Background()); err != nil {
+		log.Fatalf("failed to load signing keys: %v", err)
+	}
+	go keys.Run()
+
+	tokens := auth.NewTokenManager(keys, cfg)
+
+	hub := ws.NewHub()
+
+	authService := auth.NewService(authRepo, sessionRepo, apiKeyRepo, keys, tokens, hasher, policy, mailer, hub, cfg)
 	authHandler := auth.NewHandler(authService)
 
+	blockRepo := block.NewRepository(db)
+	blockService := block.NewService(blockRepo)
+	blockHandler := block.NewHandler(blockService)
+
+	presenceRepo := presence.NewRepository(db)
+	presenceService := presence.NewService(presenceRepo, hub)
+	presenceHandler := presence.NewHandler(presenceService)
+	hub.SetPresenceListener(presenceService)
+
+	go hub.Run()
+	go presenceService.Run()
+
+	contactRepo := contact.NewRepository(db)
+	contactService := contact.NewService(contactRepo, blockRepo, hub)
+	contactHandler := contact.NewHandler(contactService)
+
 	chatRepo := chat.NewRepository(db)
-	chatService := chat.NewService(chatRepo)
+	chatService := chat.NewService(chatRepo, blockRepo, contactRepo, hub)
 	chatHandler := chat.NewHandler(chatService)
 
 	messageRepo := messages.NewRepository(db)
-	messageService := messages.NewService(messageRepo, chatRepo)
+	messageService := messages.NewService(messageRepo, chatRepo, blockRepo, chatService, hub)
 	messageHandler := messages.NewHandler(messageService)
 
-	hub := ws.NewHub()
-	go hub.Run()
+	wsHandler := ws.NewHandler(hub, chatService, messageService, authService)
+
+	accountRepo := account.NewRepository(db)
+	accountService, err := account.NewService(accountRepo, sessionRepo, authService, hub, cfg)
+	if err != nil {
+		log.Fatalf("failed to configure account deletion: %v", err)
+	}
+	go accountService.Run()
+	accountHandler := account.NewHandler(accountService)
+
+	adminRepo := admin.NewRepository(db)
+	adminService := admin.NewService(adminRepo, sessionRepo, hub, accountService)
+	adminHandler := admin.NewHandler(adminService)
 
-	wsHandler := ws.NewHandler(hub, chatService, messageService)
+	auth.RegisterWellKnownRoutes(router.Group(""), authHandler)
 
 	api := router.Group("/api")
 	user.RegisterRoutes(api, userHandler)
 	auth.RegisterRoutes(api, authHandler)
 
-	protected := api.Group("")
-	protected.Use(auth.AuthMiddleware(cfg.JWTSecret))
-
-	chat.RegisterRoutes(protected, chatHandler)
-	messages.RegisterRoutes(protected, messageHandler)
+	if cfg.OIDCIssuerURL != "" {
+		provider := oidc.NewProvider(
+			cfg.OIDCIssuerURL,
+			cfg.OIDCClientID,
+			cfg.OIDCClientSecret,
+			cfg.OIDCRedirectURL,
+			cfg.OIDCScopes,
+			&http.Client{Timeout: 10 * time.Second},
+		)
+		oidcRepo := oidc.NewRepository(db)
+		oidcService := oidc.NewService(oidcRepo, provider, userRepo, authService, cfg)
+		oidcHandler := oidc.NewHandler(oidcService)
+		oidc.RegisterRoutes(api, oidcHandler)
+	}
 
-	ws.RegisterRoutes(protected, wsHandler)
+	protected := api.Group("")
+	protected.Use(auth.AuthMiddleware(tokens, sessionService, apiKeyService))
+
+	interactive := protected.Group("")
+	interactive.Use(apikey.RejectAPIKeys())
+
+	user.RegisterProtectedRoutes(interactive, userHandler)
+	auth.RegisterProtectedRoutes(interactive, authHandler)
+	session.RegisterRoutes(interactive, sessionHandler)
+	apikey.RegisterRoutes(interactive, apiKeyHandler)
+	account.RegisterRoutes(interactive, accountHandler)
+	block.RegisterRoutes(interactive, blockHandler)
+	contact.RegisterRoutes(interactive, contactHandler)
+	presence.RegisterRoutes(interactive, presenceHandler)
+	admin.RegisterRoutes(interactive, adminHandler)
+
+	verified := protected.Group("")
+	verified.Use(user.RequireVerifiedEmail(userService))
+
+	chat.RegisterRoutes(verified, chatHandler)
+	messages.RegisterRoutes(verified, messageHandler)
+	auth.RegisterWebSocketRoutes(verified, authHandler)
+
+	// The handshake has its own authentication because browsers cannot send
+	// an Authorization header with it.
+	websocket := api.Group("")
+	websocket.Use(auth.WebSocketAuthMiddleware(authService, tokens, sessionService, apiKeyService))
+	websocket.Use(user.RequireVerifiedEmail(userService))
+
+	ws.RegisterRoutes(websocket, wsHandler)
 
 	router.Run(":" + cfg.AppPort)
 
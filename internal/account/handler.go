@@ -0,0 +1,107 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vladopadikk/go-chat/internal/auth"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service}
+}
+
+func (h *Handler) ExportHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	export, err := h.service.Export(ctx.Request.Context(), userID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	filename := fmt.Sprintf("go-chat-export-%d-%s.json", userID, export.ExportedAt.Format("20060102"))
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.IndentedJSON(http.StatusOK, export)
+}
+
+func (h *Handler) DeleteAccountHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var input DeleteAccountInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	resp, err := h.service.RequestDeletion(ctx.Request.Context(), userID, ctx.GetInt64("sessionID"), input, auth.ClientInfo(ctx))
+	if err != nil {
+		if auth.WriteLockout(ctx, err) {
+			return
+		}
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, resp)
+}
+
+func (h *Handler) CancelDeletionHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.service.CancelDeletion(ctx.Request.Context(), userID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrInvalidCurrentPassword), errors.Is(err, auth.ErrInvalidTOTPCode),
+		errors.Is(err, auth.ErrRecentLoginRequired):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrNoPendingDeletion):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func currentUserID(ctx *gin.Context) (int64, bool) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return 0, false
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func RegisterRoutes(r *gin.RouterGroup, h *Handler) {
+	r.GET("/me/export", h.ExportHandler)
+	r.DELETE("/me", h.DeleteAccountHandler)
+	r.POST("/me/deletion/cancel", h.CancelDeletionHandler)
+}
@@ -0,0 +1,92 @@
+package account
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	MessagePolicyAnonymize = "anonymize"
+	MessagePolicyDelete    = "delete"
+)
+
+// DeleteAccountInput confirms the deletion with the password or, for
+// accounts without one, a two-factor code.
+type DeleteAccountInput struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type DeletionResponse struct {
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
+
+type Export struct {
+	ExportedAt  time.Time          `json:"exported_at"`
+	Profile     ExportProfile      `json:"profile"`
+	Chats       []ExportChat       `json:"chats"`
+	Memberships []ExportMembership `json:"memberships"`
+	Messages    []ExportMessage    `json:"messages"`
+	Sessions    []ExportSession    `json:"sessions"`
+	Blocks      []ExportBlock      `json:"blocks"`
+	Contacts    []ExportContact    `json:"contacts"`
+}
+
+type ExportProfile struct {
+	ID              int64      `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	Role            string     `json:"role"`
+	DisplayName     string     `json:"display_name"`
+	Bio             string     `json:"bio"`
+	AvatarURL       string     `json:"avatar_url"`
+	Locale          string     `json:"locale"`
+	TimeZone        string     `json:"time_zone"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type ExportChat struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Name      *string   `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportMembership struct {
+	ChatID   int64     `json:"chat_id"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type ExportMessage struct {
+	ID        int64     `json:"id"`
+	ChatID    int64     `json:"chat_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportSession struct {
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type ExportBlock struct {
+	UserID    int64     `json:"user_id"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+type ExportContact struct {
+	UserID int64     `json:"user_id"`
+	Since  time.Time `json:"since"`
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
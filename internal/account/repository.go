@@ -0,0 +1,362 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+	"github.com/vladopadikk/go-chat/internal/user"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db}
+}
+
+func (r *Repository) GetUser(ctx context.Context, exec database.Executor, userID int64) (user.User, error) {
+	query := `
+		SELECT id, username, email, password_hash, role, email_verified_at, deletion_scheduled_at, deleted_at,
+			display_name, bio, avatar_url, locale, time_zone, created_at
+		FROM users
+		WHERE id = $1;
+	`
+	var u user.User
+	err := exec.QueryRowContext(ctx, query, userID).Scan(
+		&u.ID,
+		&u.Username,
+		&u.Email,
+		&u.PasswordHash,
+		&u.Role,
+		&u.EmailVerifiedAt,
+		&u.DeletionScheduledAt,
+		&u.DeletedAt,
+		&u.DisplayName,
+		&u.Bio,
+		&u.AvatarURL,
+		&u.Locale,
+		&u.TimeZone,
+		&u.CreatedAt,
+	)
+	return u, err
+}
+
+func (r *Repository) GetChats(ctx context.Context, exec database.Executor, userID int64) ([]ExportChat, []ExportMembership, error) {
+	query := `
+		SELECT c.id, c.type, c.name, c.created_at, cm.role, cm.joined_at
+		FROM chats c
+		JOIN chat_members cm ON cm.chat_id = c.id
+		WHERE cm.user_id = $1
+		ORDER BY c.id;
+	`
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	chats := []ExportChat{}
+	memberships := []ExportMembership{}
+	for rows.Next() {
+		var chat ExportChat
+		var name sql.NullString
+		var membership ExportMembership
+		if err := rows.Scan(&chat.ID, &chat.Type, &name, &chat.CreatedAt, &membership.Role, &membership.JoinedAt); err != nil {
+			return nil, nil, err
+		}
+		if name.Valid {
+			chat.Name = &name.String
+		}
+		chats = append(chats, chat)
+		membership.ChatID = chat.ID
+		memberships = append(memberships, membership)
+	}
+
+	return chats, memberships, rows.Err()
+}
+
+func (r *Repository) GetMessages(ctx context.Context, exec database.Executor, userID int64) ([]ExportMessage, error) {
+	query := `
+		SELECT id, chat_id, content, created_at
+		FROM messages
+		WHERE sender_id = $1
+		ORDER BY id;
+	`
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []ExportMessage{}
+	for rows.Next() {
+		var m ExportMessage
+		if err := rows.Scan(&m.ID, &m.ChatID, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+func (r *Repository) GetSessions(ctx context.Context, exec database.Executor, userID int64) ([]ExportSession, error) {
+	query := `
+		SELECT user_agent, ip_address, created_at, last_used_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1
+		ORDER BY id;
+	`
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []ExportSession{}
+	for rows.Next() {
+		var s ExportSession
+		var revokedAt sql.NullTime
+		if err := rows.Scan(&s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		s.RevokedAt = nullTimePtr(revokedAt)
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *Repository) GetBlocks(ctx context.Context, exec database.Executor, userID int64) ([]ExportBlock, error) {
+	query := `
+		SELECT blocked_id, created_at
+		FROM user_blocks
+		WHERE blocker_id = $1
+		ORDER BY created_at;
+	`
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := []ExportBlock{}
+	for rows.Next() {
+		var b ExportBlock
+		if err := rows.Scan(&b.UserID, &b.BlockedAt); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+
+	return blocks, rows.Err()
+}
+
+func (r *Repository) GetContacts(ctx context.Context, exec database.Executor, userID int64) ([]ExportContact, error) {
+	query := `
+		SELECT contact_id, created_at
+		FROM contacts
+		WHERE user_id = $1
+		ORDER BY created_at;
+	`
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []ExportContact{}
+	for rows.Next() {
+		var c ExportContact
+		if err := rows.Scan(&c.UserID, &c.Since); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, c)
+	}
+
+	return contacts, rows.Err()
+}
+
+func (r *Repository) ScheduleDeletion(ctx context.Context, exec database.Executor, userID int64, at time.Time) error {
+	query := `
+		UPDATE users
+		SET deletion_scheduled_at = $2
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+	_, err := exec.ExecContext(ctx, query, userID, at)
+	return err
+}
+
+func (r *Repository) CancelDeletion(ctx context.Context, exec database.Executor, userID int64) (bool, error) {
+	query := `
+		UPDATE users
+		SET deletion_scheduled_at = NULL
+		WHERE id = $1 AND deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL;
+	`
+	res, err := exec.ExecContext(ctx, query, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *Repository) GetDueDeletions(ctx context.Context, exec database.Executor, now time.Time, limit int) ([]int64, error) {
+	query := `
+		SELECT id
+		FROM users
+		WHERE deletion_scheduled_at <= $1 AND deleted_at IS NULL
+		ORDER BY deletion_scheduled_at
+		LIMIT $2;
+	`
+	rows, err := exec.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Anonymize strips the users row of everything that identifies the person
+// while keeping the id that messages and chats refer to.
+func (r *Repository) Anonymize(ctx context.Context, exec database.Executor, userID int64, deletedAt time.Time) error {
+	query := `
+		UPDATE users
+		SET username = 'deleted-' || id,
+			email = 'deleted-' || id || '@deleted.invalid',
+			password_hash = '',
+			display_name = '',
+			bio = '',
+			avatar_url = '',
+			locale = '',
+			time_zone = '',
+			role = 'user',
+			email_verified_at = NULL,
+			verification_sent_at = NULL,
+			suspended_at = NULL,
+			last_seen_at = NULL,
+			deletion_scheduled_at = NULL,
+			deleted_at = $2
+		WHERE id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, userID, deletedAt)
+	return err
+}
+
+// DeletePersonalData removes credentials, devices and memberships of the
+// user. Refresh tokens and WebSocket tickets go with their sessions.
+func (r *Repository) DeletePersonalData(ctx context.Context, exec database.Executor, userID int64, email string) error {
+	if err := r.leaveChats(ctx, exec, userID); err != nil {
+		return err
+	}
+
+	queries := []string{
+		`DELETE FROM sessions WHERE user_id = $1;`,
+		`DELETE FROM api_keys WHERE user_id = $1;`,
+		`DELETE FROM user_totp WHERE user_id = $1;`,
+		`DELETE FROM recovery_codes WHERE user_id = $1;`,
+		`DELETE FROM user_identities WHERE user_id = $1;`,
+		`DELETE FROM password_reset_tokens WHERE user_id = $1;`,
+		`DELETE FROM email_verification_tokens WHERE user_id = $1;`,
+		`DELETE FROM user_blocks WHERE blocker_id = $1 OR blocked_id = $1;`,
+		`DELETE FROM contacts WHERE user_id = $1 OR contact_id = $1;`,
+		`DELETE FROM contact_requests WHERE sender_id = $1 OR recipient_id = $1;`,
+		`DELETE FROM chat_invitations WHERE inviter_id = $1 OR invitee_id = $1;`,
+		`UPDATE account_lockouts SET email = '', ip_address = '' WHERE user_id = $1;`,
+	}
+	for _, query := range queries {
+		if _, err := exec.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		DELETE FROM login_failures
+		WHERE key = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, "email:"+email)
+	return err
+}
+
+// leaveChats removes the user from every chat. Groups they owned pass to
+// their longest-standing admin, or member if there is no admin, and are
+// deleted if nobody else is left.
+func (r *Repository) leaveChats(ctx context.Context, exec database.Executor, userID int64) error {
+	query := `
+		DELETE FROM chat_members
+		WHERE user_id = $1
+		RETURNING chat_id, role;
+	`
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var owned []int64
+	for rows.Next() {
+		var chatID int64
+		var role string
+		if err := rows.Scan(&chatID, &role); err != nil {
+			return err
+		}
+		if role == "owner" {
+			owned = append(owned, chatID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(owned) == 0 {
+		return nil
+	}
+
+	query = `
+		UPDATE chat_members cm
+		SET role = 'owner'
+		FROM (
+			SELECT DISTINCT ON (chat_id) chat_id, user_id
+			FROM chat_members
+			WHERE chat_id = ANY($1)
+			ORDER BY chat_id, role = 'admin' DESC, joined_at, user_id
+		) next
+		WHERE cm.chat_id = next.chat_id AND cm.user_id = next.user_id;
+	`
+	if _, err := exec.ExecContext(ctx, query, owned); err != nil {
+		return err
+	}
+
+	query = `
+		DELETE FROM chats c
+		WHERE c.id = ANY($1)
+			AND NOT EXISTS (SELECT 1 FROM chat_members cm WHERE cm.chat_id = c.id);
+	`
+	_, err = exec.ExecContext(ctx, query, owned)
+	return err
+}
+
+func (r *Repository) DeleteMessages(ctx context.Context, exec database.Executor, userID int64) error {
+	query := `
+		DELETE FROM messages
+		WHERE sender_id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, userID)
+	return err
+}
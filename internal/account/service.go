@@ -0,0 +1,225 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/config"
+	"github.com/vladopadikk/go-chat/internal/session"
+)
+
+const (
+	deletionInterval  = time.Minute
+	deletionBatchSize = 100
+)
+
+var ErrUserNotFound = errors.New("user not found")
+var ErrNoPendingDeletion = errors.New("account deletion is not scheduled")
+var ErrInvalidMessagePolicy = errors.New("invalid account deletion message policy")
+
+// Disconnector closes the live connections of a user.
+type Disconnector interface {
+	DisconnectUser(userID int64)
+}
+
+// Confirmer checks that the signed-in user is the account holder, counting
+// failures towards the login lockout.
+type Confirmer interface {
+	ConfirmUser(ctx context.Context, userID, sessionID int64, password, code string, client session.ClientInfo) error
+}
+
+type Service struct {
+	repo          *Repository
+	sessionRepo   *session.Repository
+	confirmer     Confirmer
+	disconnector  Disconnector
+	grace         time.Duration
+	messagePolicy string
+}
+
+func NewService(repo *Repository, sessionRepo *session.Repository, confirmer Confirmer, disconnector Disconnector, cfg *config.Config) (*Service, error) {
+	switch cfg.AccountDeletionMessages {
+	case MessagePolicyAnonymize, MessagePolicyDelete:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMessagePolicy, cfg.AccountDeletionMessages)
+	}
+
+	return &Service{
+		repo:          repo,
+		sessionRepo:   sessionRepo,
+		confirmer:     confirmer,
+		disconnector:  disconnector,
+		grace:         cfg.AccountDeletionGrace,
+		messagePolicy: cfg.AccountDeletionMessages,
+	}, nil
+}
+
+// Export collects everything stored about the user.
+func (s *Service) Export(ctx context.Context, userID int64) (Export, error) {
+	u, err := s.repo.GetUser(ctx, s.repo.db, userID)
+	if err == sql.ErrNoRows {
+		return Export{}, ErrUserNotFound
+	}
+	if err != nil {
+		return Export{}, fmt.Errorf("db error: %w", err)
+	}
+
+	chats, memberships, err := s.repo.GetChats(ctx, s.repo.db, userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("db error: %w", err)
+	}
+
+	messages, err := s.repo.GetMessages(ctx, s.repo.db, userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("db error: %w", err)
+	}
+
+	sessions, err := s.repo.GetSessions(ctx, s.repo.db, userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("db error: %w", err)
+	}
+
+	blocks, err := s.repo.GetBlocks(ctx, s.repo.db, userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("db error: %w", err)
+	}
+
+	contacts, err := s.repo.GetContacts(ctx, s.repo.db, userID)
+	if err != nil {
+		return Export{}, fmt.Errorf("db error: %w", err)
+	}
+
+	return Export{
+		ExportedAt: time.Now(),
+		Profile: ExportProfile{
+			ID:              u.ID,
+			Username:        u.Username,
+			Email:           u.Email,
+			Role:            u.Role,
+			DisplayName:     u.DisplayName,
+			Bio:             u.Bio,
+			AvatarURL:       u.AvatarURL,
+			Locale:          u.Locale,
+			TimeZone:        u.TimeZone,
+			EmailVerifiedAt: nullTimePtr(u.EmailVerifiedAt),
+			CreatedAt:       u.CreatedAt,
+		},
+		Chats:       chats,
+		Memberships: memberships,
+		Messages:    messages,
+		Sessions:    sessions,
+		Blocks:      blocks,
+		Contacts:    contacts,
+	}, nil
+}
+
+// RequestDeletion schedules the account for erasure once the grace period has
+// passed and signs the user out everywhere. Logging in again and cancelling
+// within the grace period keeps the account.
+func (s *Service) RequestDeletion(ctx context.Context, userID, sessionID int64, input DeleteAccountInput, client session.ClientInfo) (DeletionResponse, error) {
+	if err := s.confirmer.ConfirmUser(ctx, userID, sessionID, input.Password, input.Code, client); err != nil {
+		return DeletionResponse{}, err
+	}
+
+	now := time.Now()
+	scheduledFor := now.Add(s.grace)
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return DeletionResponse{}, fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.repo.ScheduleDeletion(ctx, tx, userID, scheduledFor); err != nil {
+		return DeletionResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if err := s.sessionRepo.RevokeAllByUserID(ctx, tx, userID, 0, now); err != nil {
+		return DeletionResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return DeletionResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	s.disconnector.DisconnectUser(userID)
+	return DeletionResponse{ScheduledFor: scheduledFor}, nil
+}
+
+func (s *Service) CancelDeletion(ctx context.Context, userID int64) error {
+	cancelled, err := s.repo.CancelDeletion(ctx, s.repo.db, userID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if !cancelled {
+		return ErrNoPendingDeletion
+	}
+	return nil
+}
+
+// EraseUser anonymizes the account right away. Messages are deleted or kept
+// under the anonymized sender depending on the configured policy.
+func (s *Service) EraseUser(ctx context.Context, userID int64) error {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	u, err := s.repo.GetUser(ctx, tx, userID)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if u.DeletedAt.Valid {
+		return nil
+	}
+
+	if err := s.repo.DeletePersonalData(ctx, tx, userID, u.Email); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if s.messagePolicy == MessagePolicyDelete {
+		if err := s.repo.DeleteMessages(ctx, tx, userID); err != nil {
+			return fmt.Errorf("db error: %w", err)
+		}
+	}
+	if err := s.repo.Anonymize(ctx, tx, userID, time.Now()); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	s.disconnector.DisconnectUser(userID)
+	return nil
+}
+
+// Run periodically erases accounts whose grace period has ended.
+func (s *Service) Run() {
+	ticker := time.NewTicker(deletionInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.Background()
+
+		ids, err := s.repo.GetDueDeletions(ctx, s.repo.db, time.Now(), deletionBatchSize)
+		if err != nil {
+			log.Printf("failed to load scheduled account deletions: %v", err)
+			continue
+		}
+
+		for _, id := range ids {
+			if err := s.EraseUser(ctx, id); err != nil {
+				log.Printf("failed to erase user %d: %v", id, err)
+				continue
+			}
+			log.Printf("Erased user %d", id)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vladopadikk/go-chat/internal/auth"
+	"github.com/vladopadikk/go-chat/internal/user"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service}
+}
+
+func (h *Handler) ListUsersHandler(ctx *gin.Context) {
+	var filter UserFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid query params"})
+		return
+	}
+
+	users, err := h.service.ListUsers(ctx.Request.Context(), filter)
+	if err != nil {
+		if errors.Is(err, ErrInvalidFilter) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, users)
+}
+
+func (h *Handler) SuspendUserHandler(ctx *gin.Context) {
+	actor, targetID, ok := actorAndTarget(ctx)
+	if !ok {
+		return
+	}
+
+	u, err := h.service.Suspend(ctx.Request.Context(), actor, targetID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, u)
+}
+
+func (h *Handler) UnsuspendUserHandler(ctx *gin.Context) {
+	actor, targetID, ok := actorAndTarget(ctx)
+	if !ok {
+		return
+	}
+
+	u, err := h.service.Unsuspend(ctx.Request.Context(), actor, targetID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, u)
+}
+
+func (h *Handler) ForceLogoutHandler(ctx *gin.Context) {
+	actor, targetID, ok := actorAndTarget(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.service.ForceLogout(ctx.Request.Context(), actor, targetID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) ChangeRoleHandler(ctx *gin.Context) {
+	actor, targetID, ok := actorAndTarget(ctx)
+	if !ok {
+		return
+	}
+
+	var input RoleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	u, err := h.service.ChangeRole(ctx.Request.Context(), actor, targetID, input)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, u)
+}
+
+func (h *Handler) DeleteUserHandler(ctx *gin.Context) {
+	actor, targetID, ok := actorAndTarget(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteUser(ctx.Request.Context(), actor, targetID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) ListLockoutsHandler(ctx *gin.Context) {
+	var limit, offset int
+	if limitParam := ctx.Query("limit"); limitParam != "" {
+		l, err := strconv.Atoi(limitParam)
+		if err != nil || l <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = l
+	}
+	if offsetParam := ctx.Query("offset"); offsetParam != "" {
+		o, err := strconv.Atoi(offsetParam)
+		if err != nil || o < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+			return
+		}
+		offset = o
+	}
+	activeOnly := ctx.Query("active") == "true"
+
+	lockouts, err := h.service.ListLockouts(ctx.Request.Context(), activeOnly, limit, offset)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, lockouts)
+}
+
+func actorAndTarget(ctx *gin.Context) (Actor, int64, bool) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return Actor{}, 0, false
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return Actor{}, 0, false
+	}
+
+	targetID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return Actor{}, 0, false
+	}
+
+	return Actor{UserID: userID, Role: ctx.GetString("role")}, targetID, true
+}
+
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrCannotManage):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidRole):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// RegisterRoutes mounts the admin API. Moderators can look at users and
+// lockouts, suspend and sign people out; changing roles and deleting
+// accounts is reserved to admins.
+func RegisterRoutes(r *gin.RouterGroup, h *Handler) {
+	admin := r.Group("/admin")
+	admin.Use(auth.RequireRole(user.RoleModerator))
+	{
+		admin.GET("/users", h.ListUsersHandler)
+		admin.POST("/users/:id/suspend", h.SuspendUserHandler)
+		admin.POST("/users/:id/unsuspend", h.UnsuspendUserHandler)
+		admin.POST("/users/:id/logout", h.ForceLogoutHandler)
+		admin.PUT("/users/:id/role", auth.RequireRole(user.RoleAdmin), h.ChangeRoleHandler)
+		admin.DELETE("/users/:id", auth.RequireRole(user.RoleAdmin), h.DeleteUserHandler)
+		admin.GET("/lockouts", h.ListLockoutsHandler)
+	}
+}
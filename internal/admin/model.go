@@ -0,0 +1,64 @@
+package admin
+
+import "time"
+
+const (
+	StatusActive     = "active"
+	StatusSuspended  = "suspended"
+	StatusUnverified = "unverified"
+	StatusDeleted    = "deleted"
+)
+
+type UserFilter struct {
+	Query  string `form:"q"`
+	Role   string `form:"role"`
+	Status string `form:"status"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+type RoleInput struct {
+	Role string `json:"role"`
+}
+
+type UserResponse struct {
+	ID            int64      `json:"id"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email"`
+	Role          string     `json:"role"`
+	EmailVerified bool       `json:"email_verified"`
+	SuspendedAt   *time.Time `json:"suspended_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type UserListResponse struct {
+	Users []UserResponse `json:"users"`
+	Total int            `json:"total"`
+}
+
+type Lockout struct {
+	ID          int64
+	Scope       string
+	UserID      *int64
+	Email       string
+	IPAddress   string
+	Failures    int
+	LockedUntil time.Time
+	CreatedAt   time.Time
+}
+
+type LockoutResponse struct {
+	ID          int64     `json:"id"`
+	Scope       string    `json:"scope"`
+	UserID      *int64    `json:"user_id"`
+	Email       string    `json:"email"`
+	IPAddress   string    `json:"ip_address"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type LockoutListResponse struct {
+	Lockouts []LockoutResponse `json:"lockouts"`
+}
@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+	"github.com/vladopadikk/go-chat/internal/user"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db}
+}
+
+// ListUsers returns one page of users matching the filter together with the
+// number of matches across all pages.
+func (r *Repository) ListUsers(ctx context.Context, exec database.Executor, filter UserFilter) ([]user.User, int, error) {
+	var conds []string
+	var args []any
+
+	if filter.Query != "" {
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		conds = append(conds, fmt.Sprintf("(username ILIKE $%d OR email ILIKE $%d)", len(args), len(args)))
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conds = append(conds, fmt.Sprintf("role = $%d", len(args)))
+	}
+	switch filter.Status {
+	case StatusActive:
+		conds = append(conds, "suspended_at IS NULL", "deleted_at IS NULL")
+	case StatusSuspended:
+		conds = append(conds, "suspended_at IS NOT NULL", "deleted_at IS NULL")
+	case StatusUnverified:
+		conds = append(conds, "email_verified_at IS NULL", "deleted_at IS NULL")
+	case StatusDeleted:
+		conds = append(conds, "deleted_at IS NOT NULL")
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`
+		SELECT id, username, email, role, email_verified_at, suspended_at, created_at, COUNT(*) OVER ()
+		FROM users
+		%s
+		ORDER BY id
+		LIMIT $%d OFFSET $%d;
+	`, where, len(args)-1, len(args))
+
+	rows, err := exec.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []user.User
+	total := 0
+	for rows.Next() {
+		var u user.User
+		if err := rows.Scan(
+			&u.ID,
+			&u.Username,
+			&u.Email,
+			&u.Role,
+			&u.EmailVerifiedAt,
+			&u.SuspendedAt,
+			&u.CreatedAt,
+			&total,
+		); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+
+	return users, total, rows.Err()
+}
+
+func (r *Repository) GetUser(ctx context.Context, exec database.Executor, id int64) (user.User, error) {
+	query := `
+		SELECT id, username, email, role, email_verified_at, suspended_at, created_at
+		FROM users
+		WHERE id = $1;
+	`
+	var u user.User
+	err := exec.QueryRowContext(ctx, query, id).Scan(
+		&u.ID,
+		&u.Username,
+		&u.Email,
+		&u.Role,
+		&u.EmailVerifiedAt,
+		&u.SuspendedAt,
+		&u.CreatedAt,
+	)
+	return u, err
+}
+
+// SetSuspended suspends the user at the given time, keeping an earlier
+// suspension time, or lifts the suspension when at is not valid.
+func (r *Repository) SetSuspended(ctx context.Context, exec database.Executor, id int64, at sql.NullTime) error {
+	query := `
+		UPDATE users
+		SET suspended_at = CASE WHEN $2::timestamp IS NULL THEN NULL ELSE COALESCE(suspended_at, $2) END
+		WHERE id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, id, at)
+	return err
+}
+
+func (r *Repository) SetRole(ctx context.Context, exec database.Executor, id int64, role string) error {
+	query := `
+		UPDATE users
+		SET role = $2
+		WHERE id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, id, role)
+	return err
+}
+
+func (r *Repository) ListLockouts(ctx context.Context, exec database.Executor, activeAt sql.NullTime, limit, offset int) ([]Lockout, error) {
+	query := `
+		SELECT id, scope, user_id, email, ip_address, failures, locked_until, created_at
+		FROM account_lockouts
+		WHERE $1::timestamp IS NULL OR locked_until > $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3;
+	`
+	rows, err := exec.QueryContext(ctx, query, activeAt, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lockouts []Lockout
+	for rows.Next() {
+		var l Lockout
+		var userID sql.NullInt64
+		if err := rows.Scan(
+			&l.ID,
+			&l.Scope,
+			&userID,
+			&l.Email,
+			&l.IPAddress,
+			&l.Failures,
+			&l.LockedUntil,
+			&l.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			l.UserID = &userID.Int64
+		}
+		lockouts = append(lockouts, l)
+	}
+
+	return lockouts, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,243 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+	"github.com/vladopadikk/go-chat/internal/session"
+	"github.com/vladopadikk/go-chat/internal/user"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+var ErrUserNotFound = errors.New("user not found")
+var ErrCannotManage = errors.New("you are not allowed to manage this user")
+var ErrInvalidRole = errors.New("invalid role")
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Disconnector closes the live connections of a user.
+type Disconnector interface {
+	DisconnectUser(userID int64)
+}
+
+// Eraser anonymizes an account and removes its personal data.
+type Eraser interface {
+	EraseUser(ctx context.Context, userID int64) error
+}
+
+// Actor is the staff member performing an action.
+type Actor struct {
+	UserID int64
+	Role   string
+}
+
+type Service struct {
+	repo         *Repository
+	sessionRepo  *session.Repository
+	disconnector Disconnector
+	eraser       Eraser
+}
+
+func NewService(repo *Repository, sessionRepo *session.Repository, disconnector Disconnector, eraser Eraser) *Service {
+	return &Service{repo, sessionRepo, disconnector, eraser}
+}
+
+func (s *Service) ListUsers(ctx context.Context, filter UserFilter) (UserListResponse, error) {
+	if filter.Role != "" && !user.IsValidRole(filter.Role) {
+		return UserListResponse{}, fmt.Errorf("%w: unknown role %q", ErrInvalidFilter, filter.Role)
+	}
+	switch filter.Status {
+	case "", StatusActive, StatusSuspended, StatusUnverified, StatusDeleted:
+	default:
+		return UserListResponse{}, fmt.Errorf("%w: unknown status %q", ErrInvalidFilter, filter.Status)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPageSize
+	}
+	if filter.Limit > maxPageSize {
+		filter.Limit = maxPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	users, total, err := s.repo.ListUsers(ctx, s.repo.db, filter)
+	if err != nil {
+		return UserListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	resp := UserListResponse{Users: []UserResponse{}, Total: total}
+	for _, u := range users {
+		resp.Users = append(resp.Users, toUserResponse(u))
+	}
+	return resp, nil
+}
+
+// Suspend blocks the account from signing in, ends all of its sessions and
+// drops its WebSocket connections.
+func (s *Service) Suspend(ctx context.Context, actor Actor, targetID int64) (UserResponse, error) {
+	return s.update(ctx, actor, targetID, func(tx *sql.Tx, now time.Time) error {
+		if err := s.repo.SetSuspended(ctx, tx, targetID, sql.NullTime{Time: now, Valid: true}); err != nil {
+			return err
+		}
+		return s.sessionRepo.RevokeAllByUserID(ctx, tx, targetID, 0, now)
+	}, true)
+}
+
+func (s *Service) Unsuspend(ctx context.Context, actor Actor, targetID int64) (UserResponse, error) {
+	return s.update(ctx, actor, targetID, func(tx *sql.Tx, now time.Time) error {
+		return s.repo.SetSuspended(ctx, tx, targetID, sql.NullTime{})
+	}, false)
+}
+
+// ForceLogout revokes every session of the user. The account stays usable.
+func (s *Service) ForceLogout(ctx context.Context, actor Actor, targetID int64) error {
+	_, err := s.update(ctx, actor, targetID, func(tx *sql.Tx, now time.Time) error {
+		return s.sessionRepo.RevokeAllByUserID(ctx, tx, targetID, 0, now)
+	}, true)
+	return err
+}
+
+// ChangeRole sets the user's global role. Existing sessions are revoked so
+// that no access token keeps carrying the old role.
+func (s *Service) ChangeRole(ctx context.Context, actor Actor, targetID int64, input RoleInput) (UserResponse, error) {
+	if !user.IsValidRole(input.Role) {
+		return UserResponse{}, ErrInvalidRole
+	}
+	if !user.HasRole(actor.Role, input.Role) {
+		return UserResponse{}, ErrCannotManage
+	}
+
+	return s.update(ctx, actor, targetID, func(tx *sql.Tx, now time.Time) error {
+		if err := s.repo.SetRole(ctx, tx, targetID, input.Role); err != nil {
+			return err
+		}
+		return s.sessionRepo.RevokeAllByUserID(ctx, tx, targetID, 0, now)
+	}, true)
+}
+
+// DeleteUser erases the account immediately, skipping the grace period of a
+// self-service deletion.
+func (s *Service) DeleteUser(ctx context.Context, actor Actor, targetID int64) error {
+	if _, err := s.loadManageable(ctx, s.repo.db, actor, targetID); err != nil {
+		return err
+	}
+
+	return s.eraser.EraseUser(ctx, targetID)
+}
+
+func (s *Service) ListLockouts(ctx context.Context, activeOnly bool, limit, offset int) (LockoutListResponse, error) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	now := time.Now()
+	var activeAt sql.NullTime
+	if activeOnly {
+		activeAt = sql.NullTime{Time: now, Valid: true}
+	}
+
+	lockouts, err := s.repo.ListLockouts(ctx, s.repo.db, activeAt, limit, offset)
+	if err != nil {
+		return LockoutListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	resp := LockoutListResponse{Lockouts: []LockoutResponse{}}
+	for _, l := range lockouts {
+		resp.Lockouts = append(resp.Lockouts, LockoutResponse{
+			ID:          l.ID,
+			Scope:       l.Scope,
+			UserID:      l.UserID,
+			Email:       l.Email,
+			IPAddress:   l.IPAddress,
+			Failures:    l.Failures,
+			LockedUntil: l.LockedUntil,
+			Active:      l.LockedUntil.After(now),
+			CreatedAt:   l.CreatedAt,
+		})
+	}
+	return resp, nil
+}
+
+// update runs change in a transaction after checking that the actor may
+// manage the target, and returns the target's new state.
+func (s *Service) update(ctx context.Context, actor Actor, targetID int64, change func(tx *sql.Tx, now time.Time) error, disconnect bool) (UserResponse, error) {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return UserResponse{}, fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := s.loadManageable(ctx, tx, actor, targetID); err != nil {
+		return UserResponse{}, err
+	}
+
+	if err := change(tx, time.Now()); err != nil {
+		return UserResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	u, err := s.repo.GetUser(ctx, tx, targetID)
+	if err != nil {
+		return UserResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return UserResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	if disconnect {
+		s.disconnector.DisconnectUser(targetID)
+	}
+
+	return toUserResponse(u), nil
+}
+
+// loadManageable fetches the target and checks that the actor strictly
+// outranks it, so admins cannot act on each other. Nobody can manage their
+// own account through the admin API.
+func (s *Service) loadManageable(ctx context.Context, exec database.Executor, actor Actor, targetID int64) (user.User, error) {
+	u, err := s.repo.GetUser(ctx, exec, targetID)
+	if err == sql.ErrNoRows {
+		return user.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return user.User{}, fmt.Errorf("db error: %w", err)
+	}
+
+	if u.ID == actor.UserID {
+		return user.User{}, ErrCannotManage
+	}
+	if !user.Outranks(actor.Role, u.Role) {
+		return user.User{}, ErrCannotManage
+	}
+
+	return u, nil
+}
+
+func toUserResponse(u user.User) UserResponse {
+	resp := UserResponse{
+		ID:            u.ID,
+		Username:      u.Username,
+		Email:         u.Email,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerifiedAt.Valid,
+		CreatedAt:     u.CreatedAt,
+	}
+	if u.SuspendedAt.Valid {
+		resp.SuspendedAt = &u.SuspendedAt.Time
+	}
+	return resp
+}
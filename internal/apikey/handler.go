@@ -0,0 +1,107 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service}
+}
+
+func (h *Handler) CreateAPIKeyHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var input CreateAPIKeyInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	key, err := h.service.Create(ctx.Request.Context(), userID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidName),
+			errors.Is(err, ErrInvalidScopes),
+			errors.Is(err, ErrInvalidExpiry):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, key)
+}
+
+func (h *Handler) GetAPIKeysHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.List(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, keys)
+}
+
+func (h *Handler) RevokeAPIKeyHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		return
+	}
+
+	if err := h.service.Revoke(ctx.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func currentUserID(ctx *gin.Context) (int64, bool) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return 0, false
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func RegisterRoutes(r *gin.RouterGroup, h *Handler) {
+	keys := r.Group("/api-keys")
+	{
+		keys.POST("", h.CreateAPIKeyHandler)
+		keys.GET("", h.GetAPIKeysHandler)
+		keys.DELETE("/:id", h.RevokeAPIKeyHandler)
+	}
+}
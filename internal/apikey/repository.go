@@ -0,0 +1,131 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db}
+}
+
+func (r *Repository) Create(ctx context.Context, exec database.Executor, key APIKey) (APIKey, error) {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	err := exec.QueryRowContext(ctx, query,
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		strings.Join(key.Scopes, " "),
+		key.ExpiresAt,
+	).Scan(&key.ID, &key.CreatedAt)
+	return key, err
+}
+
+func (r *Repository) GetActiveByUserID(ctx context.Context, exec database.Executor, userID int64) ([]APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at, expires_at, revoked_at
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC;
+	`
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Use looks up a usable key by hash and records that it was used.
+func (r *Repository) Use(ctx context.Context, exec database.Executor, keyHash string, now time.Time) (APIKey, error) {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE key_hash = $1
+			AND revoked_at IS NULL
+			AND (expires_at IS NULL OR expires_at > $2)
+			AND NOT EXISTS (
+				SELECT 1 FROM users u
+				WHERE u.id = api_keys.user_id AND u.suspended_at IS NOT NULL
+			)
+		RETURNING id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at, expires_at, revoked_at;
+	`
+	return scanAPIKey(exec.QueryRowContext(ctx, query, keyHash, now))
+}
+
+func (r *Repository) Revoke(ctx context.Context, exec database.Executor, id, userID int64, revokedAt time.Time) (bool, error) {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+	`
+	res, err := exec.ExecContext(ctx, query, id, userID, revokedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RevokeAllByUserID revokes every active key of the user, e.g. after a
+// password change.
+func (r *Repository) RevokeAllByUserID(ctx context.Context, exec database.Executor, userID int64, revokedAt time.Time) error {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL;
+	`
+	_, err := exec.ExecContext(ctx, query, userID, revokedAt)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row scanner) (APIKey, error) {
+	var key APIKey
+	var scopes string
+
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&scopes,
+		&key.CreatedAt,
+		&key.LastUsedAt,
+		&key.ExpiresAt,
+		&key.RevokedAt,
+	)
+	key.Scopes = strings.Fields(scopes)
+	return key, err
+}
@@ -0,0 +1,92 @@
+package apikey
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ScopeChatsRead     = "chats:read"
+	ScopeChatsWrite    = "chats:write"
+	ScopeMessagesRead  = "messages:read"
+	ScopeMessagesWrite = "messages:write"
+)
+
+// Keys set on the gin context for requests authenticated with an API key.
+const (
+	ContextAuthMethod = "authMethod"
+	ContextScopes     = "apiKeyScopes"
+
+	AuthMethodAPIKey = "api_key"
+)
+
+var validScopes = []string{
+	ScopeChatsRead,
+	ScopeChatsWrite,
+	ScopeMessagesRead,
+	ScopeMessagesWrite,
+}
+
+// routeScopes maps "METHOD /full/path" of every route registered with Handle
+// to the scope it requires.
+var routeScopes sync.Map
+
+func isValidScope(scope string) bool {
+	return slices.Contains(validScopes, scope)
+}
+
+// Handle registers a route that API keys carrying scope may call. API keys are
+// rejected on every route not registered this way, so a route that does not
+// declare a scope fails closed.
+func Handle(router *gin.RouterGroup, method, relativePath, scope string, handlers ...gin.HandlerFunc) {
+	routeScopes.Store(method+" "+path.Join(router.BasePath(), relativePath), scope)
+	router.Handle(method, relativePath, append([]gin.HandlerFunc{requireScope(scope)}, handlers...)...)
+}
+
+// RouteScope returns the scope an API key needs for the matched route of the
+// request, or false if API keys may not call it at all.
+func RouteScope(ctx *gin.Context) (string, bool) {
+	scope, ok := routeScopes.Load(ctx.Request.Method + " " + ctx.FullPath())
+	if !ok {
+		return "", false
+	}
+	return scope.(string), true
+}
+
+// requireScope lets API key requests through only if the key carries the
+// scope. Requests authenticated with a user's access token are not limited.
+func requireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		scopesAny, exist := ctx.Get(ContextScopes)
+		if !exist {
+			ctx.Next()
+			return
+		}
+
+		scopes, _ := scopesAny.([]string)
+		if !slices.Contains(scopes, scope) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "api key is missing scope " + scope})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// RejectAPIKeys keeps API keys away from account management endpoints.
+func RejectAPIKeys() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetString(ContextAuthMethod) == AuthMethodAPIKey {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "endpoint is not available to api keys"})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,177 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// KeyPrefix marks API keys so they can be told apart from JWTs in an
+	// Authorization header.
+	KeyPrefix = "gck_"
+
+	maxNameLength = 100
+)
+
+var ErrInvalidAPIKey = errors.New("invalid api key")
+var ErrAPIKeyNotFound = errors.New("api key not found")
+var ErrInvalidName = errors.New("api key name is required and must be at most 100 characters")
+var ErrInvalidScopes = errors.New("at least one valid scope is required")
+var ErrInvalidExpiry = errors.New("expiry must be in the future")
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo}
+}
+
+// Create issues a new key for the user. The plain key is part of the response
+// only; the database keeps its hash.
+func (s *Service) Create(ctx context.Context, userID int64, input CreateAPIKeyInput) (CreatedAPIKeyResponse, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(name) > maxNameLength {
+		return CreatedAPIKeyResponse{}, ErrInvalidName
+	}
+
+	scopes, err := normalizeScopes(input.Scopes)
+	if err != nil {
+		return CreatedAPIKeyResponse{}, err
+	}
+
+	key := APIKey{
+		UserID: userID,
+		Name:   name,
+		Scopes: scopes,
+	}
+	if input.ExpiresAt != nil {
+		if !input.ExpiresAt.After(time.Now()) {
+			return CreatedAPIKeyResponse{}, ErrInvalidExpiry
+		}
+		key.ExpiresAt = sql.NullTime{Time: *input.ExpiresAt, Valid: true}
+	}
+
+	raw, prefix, err := newAPIKey()
+	if err != nil {
+		return CreatedAPIKeyResponse{}, err
+	}
+	key.Prefix = prefix
+	key.KeyHash = hashKey(raw)
+
+	key, err = s.repo.Create(ctx, s.repo.db, key)
+	if err != nil {
+		return CreatedAPIKeyResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	return CreatedAPIKeyResponse{
+		APIKeyResponse: toResponse(key),
+		Key:            raw,
+	}, nil
+}
+
+func (s *Service) List(ctx context.Context, userID int64) (APIKeyListResponse, error) {
+	keys, err := s.repo.GetActiveByUserID(ctx, s.repo.db, userID)
+	if err != nil {
+		return APIKeyListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	resp := APIKeyListResponse{APIKeys: []APIKeyResponse{}}
+	for _, key := range keys {
+		resp.APIKeys = append(resp.APIKeys, toResponse(key))
+	}
+
+	return resp, nil
+}
+
+func (s *Service) Revoke(ctx context.Context, userID, id int64) error {
+	revoked, err := s.repo.Revoke(ctx, s.repo.db, id, userID, time.Now())
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Authenticate resolves a presented key to its owner and scopes.
+func (s *Service) Authenticate(ctx context.Context, raw string) (APIKey, error) {
+	if !strings.HasPrefix(raw, KeyPrefix) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.Use(ctx, s.repo.db, hashKey(raw), time.Now())
+	if err == sql.ErrNoRows {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return APIKey{}, fmt.Errorf("db error: %w", err)
+	}
+
+	return key, nil
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	var result []string
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !isValidScope(scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidScopes, scope)
+		}
+		if !slices.Contains(result, scope) {
+			result = append(result, scope)
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrInvalidScopes
+	}
+	return result, nil
+}
+
+// newAPIKey returns a key of the form gck_<prefix>_<secret>. The prefix is
+// stored in clear so owners can recognise their keys in listings.
+func newAPIKey() (string, string, error) {
+	p := make([]byte, 4)
+	if _, err := rand.Read(p); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	prefix := KeyPrefix + hex.EncodeToString(p)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+func hashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func toResponse(key APIKey) APIKeyResponse {
+	resp := APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if key.LastUsedAt.Valid {
+		resp.LastUsedAt = &key.LastUsedAt.Time
+	}
+	if key.ExpiresAt.Valid {
+		resp.ExpiresAt = &key.ExpiresAt.Time
+	}
+	return resp
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/session"
+)
+
+var ErrRecentLoginRequired = errors.New("sign in again to confirm this action")
+
+// ConfirmUser checks that the signed-in user really is the account holder
+// before a sensitive action. Accounts with a password must give it; accounts
+// without one (signed up through OpenID Connect) give a two-factor code if
+// they have it enabled and otherwise must have signed in to the session
+// within ReauthMaxAge. Failed attempts count towards the login lockout.
+func (s *Service) ConfirmUser(ctx context.Context, userID, sessionID int64, password, code string, client session.ClientInfo) error {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if u == nil {
+		return ErrInvalidCredentials
+	}
+
+	if err := s.checkLoginThrottle(ctx, u.Email, client.IPAddress); err != nil {
+		return err
+	}
+
+	if u.PasswordHash != "" {
+		ok, err := s.hasher.Verify(password, u.PasswordHash)
+		if err != nil {
+			return fmt.Errorf("verify password: %w", err)
+		}
+		if !ok {
+			if err := s.recordLoginFailure(ctx, u.ID, u.Email, client.IPAddress); err != nil {
+				return err
+			}
+			return ErrInvalidCurrentPassword
+		}
+		return s.resetLoginFailures(ctx, u.Email)
+	}
+
+	enabled, err := s.isTOTPEnabled(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return s.checkRecentLogin(ctx, u.ID, sessionID)
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	ok, err := s.verifySecondFactor(ctx, tx, u.ID, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		tx.Rollback()
+		if err := s.recordLoginFailure(ctx, u.ID, u.Email, client.IPAddress); err != nil {
+			return err
+		}
+		return ErrInvalidTOTPCode
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return s.resetLoginFailures(ctx, u.Email)
+}
+
+// checkRecentLogin fails unless the session was created within ReauthMaxAge.
+func (s *Service) checkRecentLogin(ctx context.Context, userID, sessionID int64) error {
+	sess, err := s.sessionRepo.GetActive(ctx, s.repo.db, sessionID, userID)
+	if err == sql.ErrNoRows {
+		return ErrRecentLoginRequired
+	}
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if time.Since(sess.CreatedAt) > s.cfg.ReauthMaxAge {
+		return ErrRecentLoginRequired
+	}
+	return nil
+}
@@ -1,9 +1,14 @@
 package auth
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/vladopadikk/go-chat/internal/password"
+	"github.com/vladopadikk/go-chat/internal/session"
 )
 
 type Handler struct {
@@ -22,16 +27,19 @@ func (h *Handler) LoginHandler(ctx *gin.Context) {
 		return
 	}
 
-	tokens, err := h.service.Login(ctx.Request.Context(), loginIn)
+	tokens, err := h.service.Login(ctx.Request.Context(), loginIn, ClientInfo(ctx))
 	if err != nil {
-		switch err {
-		case ErrUserNotFound:
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		if WriteLockout(ctx, err) {
 			return
-		case ErrInvalidPassword:
+		}
+		if errors.Is(err, ErrInvalidCredentials) {
 			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
+		if errors.Is(err, ErrAccountSuspended) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -40,6 +48,290 @@ func (h *Handler) LoginHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, tokens)
 }
 
+func (h *Handler) LoginMFAHandler(ctx *gin.Context) {
+	var input MFALoginInput
+
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	tokens, err := h.service.LoginMFA(ctx.Request.Context(), input, ClientInfo(ctx))
+	if err != nil {
+		if WriteLockout(ctx, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, ErrInvalidChallenge),
+			errors.Is(err, ErrInvalidTOTPCode),
+			errors.Is(err, ErrTOTPNotEnabled):
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, ErrAccountSuspended):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+func (h *Handler) EnrollTOTPHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.EnrollTOTP(ctx.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, ErrTOTPAlreadyEnabled) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) ConfirmTOTPHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var input TOTPCodeInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	resp, err := h.service.ConfirmTOTP(ctx.Request.Context(), userID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidTOTPCode), errors.Is(err, ErrTOTPNotEnrolled):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, ErrTOTPAlreadyEnabled):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) DisableTOTPHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var input TOTPDisableInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	if err := h.service.DisableTOTP(ctx.Request.Context(), userID, input); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidCredentials), errors.Is(err, ErrInvalidTOTPCode):
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, ErrTOTPNotEnabled):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) RefreshHandler(ctx *gin.Context) {
+	var refreshIn RefreshInput
+
+	if err := ctx.ShouldBindJSON(&refreshIn); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	tokens, err := h.service.Refresh(ctx.Request.Context(), refreshIn)
+	if err != nil {
+		switch err {
+		case ErrInvalidRefreshToken, ErrRefreshTokenReused:
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+func (h *Handler) ForgotPasswordHandler(ctx *gin.Context) {
+	var input ForgotPasswordInput
+
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	h.service.ForgotPassword(ctx.Request.Context(), input)
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "if the email is registered, a reset link has been sent"})
+}
+
+func (h *Handler) ResetPasswordHandler(ctx *gin.Context) {
+	var input ResetPasswordInput
+
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	if err := h.service.ResetPassword(ctx.Request.Context(), input); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidResetToken),
+			password.IsPolicyError(err):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) ChangePasswordHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var input ChangePasswordInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	tokens, err := h.service.ChangePassword(ctx.Request.Context(), userID, input, ClientInfo(ctx))
+	if err != nil {
+		if WriteLockout(ctx, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, ErrInvalidCurrentPassword):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, ErrSamePassword),
+			password.IsPolicyError(err):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+func (h *Handler) JWKSHandler(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, h.service.JWKS())
+}
+
+func (h *Handler) WSTicketHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	sessionID := ctx.GetInt64("sessionID")
+	expiresAt := ctx.GetTime("authExpiresAt")
+	if sessionID == 0 || expiresAt.IsZero() {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "websocket tickets require a user access token"})
+		return
+	}
+
+	ticket, err := h.service.IssueWSTicket(ctx.Request.Context(), userID, sessionID, ctx.GetString("role"), expiresAt)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, ticket)
+}
+
+func currentUserID(ctx *gin.Context) (int64, bool) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return 0, false
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// WriteLockout answers 429 with a Retry-After header if err is a
+// LockoutError and reports whether it did.
+func WriteLockout(ctx *gin.Context, err error) bool {
+	var lockoutErr *LockoutError
+	if !errors.As(err, &lockoutErr) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(lockoutErr.RetryAfter.Seconds()))
+	ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+	ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	return true
+}
+
+// ClientInfo describes the device a request came from.
+func ClientInfo(ctx *gin.Context) session.ClientInfo {
+	return session.ClientInfo{
+		UserAgent: ctx.Request.UserAgent(),
+		IPAddress: ctx.ClientIP(),
+	}
+}
+
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/login", handler.LoginHandler)
+	router.POST("/login/2fa", handler.LoginMFAHandler)
+	router.POST("/refresh", handler.RefreshHandler)
+	router.POST("/password/forgot", handler.ForgotPasswordHandler)
+	router.POST("/password/reset", handler.ResetPasswordHandler)
+}
+
+func RegisterProtectedRoutes(router *gin.RouterGroup, handler *Handler) {
+	mfa := router.Group("/2fa")
+	{
+		mfa.POST("/enroll", handler.EnrollTOTPHandler)
+		mfa.POST("/confirm", handler.ConfirmTOTPHandler)
+		mfa.POST("/disable", handler.DisableTOTPHandler)
+	}
+	router.POST("/me/password", handler.ChangePasswordHandler)
+}
+
+func RegisterWebSocketRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.POST("/ws/ticket", handler.WSTicketHandler)
+}
+
+func RegisterWellKnownRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.GET("/.well-known/jwks.json", handler.JWKSHandler)
 }
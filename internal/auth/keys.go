@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vladopadikk/go-chat/internal/config"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	rsaKeyBits            = 2048
+	keyRefreshInterval    = time.Minute
+	keyMissReloadInterval = 10 * time.Second
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+var ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	createdAt time.Time
+	expiresAt time.Time
+}
+
+// KeyManager owns the asymmetric keys used to sign tokens. The newest key
+// signs until the rotation period elapses; every key keeps verifying until
+// the longest-lived token it could have signed has expired.
+type KeyManager struct {
+	repo      *Repository
+	algorithm string
+	rotation  time.Duration
+	verifyFor time.Duration
+
+	mu      sync.RWMutex
+	keys    map[string]*signingKey
+	current *signingKey
+	// missReloadAt is when an unknown kid last caused a reload.
+	missReloadAt time.Time
+}
+
+func NewKeyManager(repo *Repository, cfg *config.Config) *KeyManager {
+	return &KeyManager{
+		repo:      repo,
+		algorithm: cfg.JWTSigningAlg,
+		rotation:  cfg.JWTKeyRotation,
+		verifyFor: cfg.JWTKeyRotation + cfg.RefreshTokenTTL,
+		keys:      make(map[string]*signingKey),
+	}
+}
+
+// Load reads the stored keys and generates a fresh signing key when none of
+// them is young enough to sign.
+func (m *KeyManager) Load(ctx context.Context) error {
+	if m.algorithm != AlgRS256 && m.algorithm != AlgEdDSA {
+		return fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, m.algorithm)
+	}
+
+	now := time.Now()
+
+	stored, err := m.repo.GetSigningKeys(ctx, m.repo.db, now)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	keys := make(map[string]*signingKey, len(stored))
+	var current *signingKey
+
+	for _, sk := range stored {
+		key, err := decodeSigningKey(sk)
+		if err != nil {
+			return err
+		}
+		keys[key.kid] = key
+
+		if key.method.Alg() == m.algorithm && now.Before(key.createdAt.Add(m.rotation)) {
+			if current == nil || key.createdAt.After(current.createdAt) {
+				current = key
+			}
+		}
+	}
+
+	if current == nil {
+		current, err = m.generate(ctx, now)
+		if err != nil {
+			return err
+		}
+		keys[current.kid] = current
+		log.Printf("Generated new %s signing key kid=%s", m.algorithm, current.kid)
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.current = current
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Run periodically reloads the key set, rotating the signing key when it
+// becomes due and dropping keys that can no longer verify anything.
+func (m *KeyManager) Run() {
+	ticker := time.NewTicker(keyRefreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.Background()
+
+		if err := m.Load(ctx); err != nil {
+			log.Printf("failed to reload signing keys: %v", err)
+			continue
+		}
+		if err := m.repo.DeleteExpiredSigningKeys(ctx, m.repo.db, time.Now()); err != nil {
+			log.Printf("failed to delete expired signing keys: %v", err)
+		}
+	}
+}
+
+func (m *KeyManager) JWKS() JWKSResponse {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	resp := JWKSResponse{Keys: []JWK{}}
+	for _, key := range m.keys {
+		resp.Keys = append(resp.Keys, publicJWK(key))
+	}
+	return resp
+}
+
+func (m *KeyManager) signingKey() (*signingKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.current == nil {
+		return nil, ErrUnknownKey
+	}
+	return m.current, nil
+}
+
+// verificationKey looks a key up by kid. An unknown kid may belong to a key
+// another instance rotated in since the last reload, so it triggers a reload
+// of the stored keys, but at most once per keyMissReloadInterval: the kid
+// comes from unauthenticated input and must not be able to drive database
+// load.
+func (m *KeyManager) verificationKey(ctx context.Context, kid string) (*signingKey, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	key, ok := m.keys[kid]
+	reload := !ok && kid != "" && now.Sub(m.missReloadAt) >= keyMissReloadInterval
+	if reload {
+		m.missReloadAt = now
+	}
+	m.mu.Unlock()
+
+	if reload {
+		if err := m.reloadVerificationKeys(ctx, now); err != nil {
+			log.Printf("failed to reload signing keys: %v", err)
+			return nil, ErrUnknownKey
+		}
+
+		m.mu.RLock()
+		key, ok = m.keys[kid]
+		m.mu.RUnlock()
+	}
+
+	if !ok || now.After(key.expiresAt) {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// reloadVerificationKeys adds stored keys missing from the cache without
+// touching the current signing key.
+func (m *KeyManager) reloadVerificationKeys(ctx context.Context, now time.Time) error {
+	stored, err := m.repo.GetSigningKeys(ctx, m.repo.db, now)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, sk := range stored {
+		if _, ok := m.keys[sk.KID]; ok {
+			continue
+		}
+		key, err := decodeSigningKey(sk)
+		if err != nil {
+			return err
+		}
+		m.keys[key.kid] = key
+	}
+	return nil
+}
+
+func (m *KeyManager) generate(ctx context.Context, now time.Time) (*signingKey, error) {
+	var private crypto.Signer
+	var method jwt.SigningMethod
+
+	switch m.algorithm {
+	case AlgRS256:
+		rsaKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		private, method = rsaKey, jwt.SigningMethodRS256
+	case AlgEdDSA:
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private, method = edKey, jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, m.algorithm)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{
+		kid:       kid,
+		method:    method,
+		private:   private,
+		createdAt: now,
+		expiresAt: now.Add(m.verifyFor),
+	}
+
+	err = m.repo.CreateSigningKey(ctx, m.repo.db, SigningKey{
+		KID:        key.kid,
+		Algorithm:  m.algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		CreatedAt:  key.createdAt,
+		ExpiresAt:  key.expiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("db error: %w", err)
+	}
+
+	return key, nil
+}
+
+func decodeSigningKey(sk SigningKey) (*signingKey, error) {
+	block, _ := pem.Decode([]byte(sk.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s: invalid pem", sk.KID)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", sk.KID, err)
+	}
+
+	key := &signingKey{
+		kid:       sk.KID,
+		createdAt: sk.CreatedAt,
+		expiresAt: sk.ExpiresAt,
+	}
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.private, key.method = private, jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		key.private, key.method = private, jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("signing key %s: %w", sk.KID, ErrUnsupportedAlgorithm)
+	}
+
+	if key.method.Alg() != sk.Algorithm {
+		return nil, fmt.Errorf("signing key %s: algorithm mismatch", sk.KID)
+	}
+
+	return key, nil
+}
+
+func publicJWK(key *signingKey) JWK {
+	jwk := JWK{
+		Use: "sig",
+		Alg: key.method.Alg(),
+		Kid: key.kid,
+	}
+
+	switch public := key.private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+
+	return jwk
+}
@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+	"github.com/vladopadikk/go-chat/internal/session"
+)
+
+const recoveryCodeCount = 10
+
+var ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+var ErrTOTPNotEnrolled = errors.New("two-factor authentication enrolment has not been started")
+var ErrTOTPNotEnabled = errors.New("two-factor authentication is not enabled")
+var ErrInvalidTOTPCode = errors.New("invalid authentication code")
+var ErrInvalidChallenge = errors.New("invalid or expired challenge token")
+
+// EnrollTOTP generates a new secret for the user. It only becomes active once
+// ConfirmTOTP is called with a code produced from it.
+func (s *Service) EnrollTOTP(ctx context.Context, userID int64) (TOTPEnrollResponse, error) {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return TOTPEnrollResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if u == nil {
+		return TOTPEnrollResponse{}, ErrInvalidCredentials
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return TOTPEnrollResponse{}, err
+	}
+
+	saved, err := s.repo.SavePendingTOTP(ctx, s.repo.db, userID, secret)
+	if err != nil {
+		return TOTPEnrollResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if !saved {
+		return TOTPEnrollResponse{}, ErrTOTPAlreadyEnabled
+	}
+
+	return TOTPEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: totpProvisioningURI(s.cfg.TOTPIssuer, u.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP activates a pending enrolment and returns freshly generated
+// recovery codes. The codes are only stored hashed and are never shown again.
+func (s *Service) ConfirmTOTP(ctx context.Context, userID int64, input TOTPCodeInput) (RecoveryCodesResponse, error) {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RecoveryCodesResponse{}, fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	totp, err := s.repo.GetTOTPForUpdate(ctx, tx, userID)
+	if err == sql.ErrNoRows {
+		return RecoveryCodesResponse{}, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return RecoveryCodesResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if totp.ConfirmedAt.Valid {
+		return RecoveryCodesResponse{}, ErrTOTPAlreadyEnabled
+	}
+
+	now := time.Now()
+
+	step, ok := verifyTOTP(totp.Secret, input.Code, now, totp.LastUsedStep)
+	if !ok {
+		return RecoveryCodesResponse{}, ErrInvalidTOTPCode
+	}
+
+	if err := s.repo.ConfirmTOTP(ctx, tx, userID, now, step); err != nil {
+		return RecoveryCodesResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	codes, err := s.replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return RecoveryCodesResponse{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RecoveryCodesResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTOTP turns two-factor authentication off after checking both the
+// password and a current code or recovery code.
+func (s *Service) DisableTOTP(ctx context.Context, userID int64, input TOTPDisableInput) error {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if u == nil {
+		return ErrInvalidCredentials
+	}
+
+	if !s.checkPassword(u, input.Password) {
+		return ErrInvalidCredentials
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	ok, err := s.verifySecondFactor(ctx, tx, userID, input.Code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTOTPCode
+	}
+
+	if err := s.repo.DeleteTOTP(ctx, tx, userID); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if err := s.repo.DeleteRecoveryCodes(ctx, tx, userID); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// LoginMFA completes a two-factor login by exchanging the challenge token
+// from Login together with a TOTP or recovery code for a token pair.
+func (s *Service) LoginMFA(ctx context.Context, input MFALoginInput, client session.ClientInfo) (TokenResponse, error) {
+	claims, err := s.tokens.ParseChallengeToken(ctx, input.ChallengeToken)
+	if err != nil {
+		return TokenResponse{}, ErrInvalidChallenge
+	}
+
+	u, err := s.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if u == nil {
+		return TokenResponse{}, ErrInvalidChallenge
+	}
+	if u.SuspendedAt.Valid {
+		return TokenResponse{}, ErrAccountSuspended
+	}
+
+	if err := s.checkLoginThrottle(ctx, u.Email, client.IPAddress); err != nil {
+		return TokenResponse{}, err
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	ok, err := s.verifySecondFactor(ctx, tx, u.ID, input.Code)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	if !ok {
+		tx.Rollback()
+		if err := s.recordLoginFailure(ctx, u.ID, u.Email, client.IPAddress); err != nil {
+			return TokenResponse{}, err
+		}
+		return TokenResponse{}, ErrInvalidTOTPCode
+	}
+
+	if err := tx.Commit(); err != nil {
+		return TokenResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	if err := s.resetLoginFailures(ctx, u.Email); err != nil {
+		return TokenResponse{}, err
+	}
+
+	return s.startSession(ctx, u.ID, client)
+}
+
+func (s *Service) isTOTPEnabled(ctx context.Context, userID int64) (bool, error) {
+	enabled, err := s.repo.IsTOTPEnabled(ctx, s.repo.db, userID)
+	if err != nil {
+		return false, fmt.Errorf("db error: %w", err)
+	}
+	return enabled, nil
+}
+
+// verifySecondFactor accepts either a TOTP code, which then cannot be used
+// again, or an unused recovery code, which is consumed.
+func (s *Service) verifySecondFactor(ctx context.Context, exec database.Executor, userID int64, code string) (bool, error) {
+	totp, err := s.repo.GetTOTPForUpdate(ctx, exec, userID)
+	if err == sql.ErrNoRows {
+		return false, ErrTOTPNotEnabled
+	}
+	if err != nil {
+		return false, fmt.Errorf("db error: %w", err)
+	}
+	if !totp.ConfirmedAt.Valid {
+		return false, ErrTOTPNotEnabled
+	}
+
+	now := time.Now()
+
+	if step, ok := verifyTOTP(totp.Secret, code, now, totp.LastUsedStep); ok {
+		if err := s.repo.UpdateTOTPStep(ctx, exec, userID, step); err != nil {
+			return false, fmt.Errorf("db error: %w", err)
+		}
+		return true, nil
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, exec, userID, HashToken(normalizeRecoveryCode(code)), now)
+	if err != nil {
+		return false, fmt.Errorf("db error: %w", err)
+	}
+	return used, nil
+}
+
+func (s *Service) replaceRecoveryCodes(ctx context.Context, exec database.Executor, userID int64) ([]string, error) {
+	if err := s.repo.DeleteRecoveryCodes(ctx, exec, userID); err != nil {
+		return nil, fmt.Errorf("db error: %w", err)
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.repo.CreateRecoveryCode(ctx, exec, userID, HashToken(normalizeRecoveryCode(code))); err != nil {
+			return nil, fmt.Errorf("db error: %w", err)
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
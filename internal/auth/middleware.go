@@ -1,16 +1,35 @@
 package auth
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/vladopadikk/go-chat/internal/apikey"
+	"github.com/vladopadikk/go-chat/internal/user"
 )
 
-func AuthMiddleware(secret string) gin.HandlerFunc {
+// WSSubprotocolBearer is the WebSocket subprotocol browsers offer, followed by
+// the access token itself, to authenticate a handshake.
+const WSSubprotocolBearer = "bearer"
+
+// SessionChecker reports whether the session an access token belongs to is
+// still active. It is implemented by session.Service.
+type SessionChecker interface {
+	Touch(ctx context.Context, sessionID, userID int64) (bool, error)
+}
+
+// AuthMiddleware accepts either a Bearer access token or an API key, sent in
+// the X-API-Key header or as a Bearer token carrying the API key prefix.
+func AuthMiddleware(tokens *TokenManager, sessions SessionChecker, apiKeys *apikey.Service) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if key := strings.TrimSpace(ctx.GetHeader("X-API-Key")); key != "" {
+			authenticateAPIKey(ctx, apiKeys, key)
+			return
+		}
+
 		authHeader := ctx.GetHeader("Authorization")
 		if authHeader == "" {
 			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header missing"})
@@ -33,29 +52,136 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
 			return
 		}
 
-		claims := &Claims{}
+		if strings.HasPrefix(token, apikey.KeyPrefix) {
+			authenticateAPIKey(ctx, apiKeys, token)
+			return
+		}
+
+		authenticateAccessToken(ctx, tokens, sessions, token)
+	}
+}
+
+// WebSocketAuthMiddleware authenticates WebSocket handshakes. Browsers cannot
+// set headers on them, so besides everything AuthMiddleware accepts it takes
+// a single-use ticket in the ticket query parameter or an access token
+// offered as the subprotocol pair "bearer, <token>".
+func WebSocketAuthMiddleware(service *Service, tokens *TokenManager, sessions SessionChecker, apiKeys *apikey.Service) gin.HandlerFunc {
+	headerAuth := AuthMiddleware(tokens, sessions, apiKeys)
 
-		parsedToken, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method")
+	return func(ctx *gin.Context) {
+		if raw := ctx.Query("ticket"); raw != "" {
+			ticket, err := service.RedeemWSTicket(ctx.Request.Context(), raw)
+			if err != nil {
+				if errors.Is(err, ErrInvalidWSTicket) {
+					ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				} else {
+					ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				}
+				ctx.Abort()
+				return
 			}
-			return []byte(secret), nil
-		})
 
-		if err != nil || !parsedToken.Valid {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
-			ctx.Abort()
+			ctx.Set("userID", ticket.UserID)
+			ctx.Set("sessionID", ticket.SessionID)
+			ctx.Set("role", ticket.Role)
+			ctx.Set("authExpiresAt", ticket.AuthExpiresAt)
+			ctx.Next()
 			return
 		}
 
-		if claims.UserID == 0 {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id in token"})
-			ctx.Abort()
+		if token, ok := subprotocolToken(ctx.Request); ok {
+			authenticateAccessToken(ctx, tokens, sessions, token)
 			return
 		}
 
-		ctx.Set("userID", claims.UserID)
+		headerAuth(ctx)
+	}
+}
+
+// RequireRole lets the request through only if the caller's global role is
+// at least min. API keys never carry a role.
+func RequireRole(min string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !user.HasRole(ctx.GetString("role"), min) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "insufficient role"})
+			ctx.Abort()
+			return
+		}
 
 		ctx.Next()
 	}
 }
+
+func authenticateAccessToken(ctx *gin.Context, tokens *TokenManager, sessions SessionChecker, token string) {
+	claims, err := tokens.ParseAccessToken(ctx.Request.Context(), token)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		ctx.Abort()
+		return
+	}
+
+	active, err := sessions.Touch(ctx.Request.Context(), claims.SessionID, claims.UserID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Abort()
+		return
+	}
+	if !active {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "session has been revoked"})
+		ctx.Abort()
+		return
+	}
+
+	ctx.Set("userID", claims.UserID)
+	ctx.Set("sessionID", claims.SessionID)
+	ctx.Set("role", claims.Role)
+	ctx.Set("authExpiresAt", claims.ExpiresAt.Time)
+
+	ctx.Next()
+}
+
+func authenticateAPIKey(ctx *gin.Context, apiKeys *apikey.Service, raw string) {
+	if _, ok := apikey.RouteScope(ctx); !ok {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "endpoint is not available to api keys"})
+		ctx.Abort()
+		return
+	}
+
+	key, err := apiKeys.Authenticate(ctx.Request.Context(), raw)
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalidAPIKey) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		ctx.Abort()
+		return
+	}
+
+	ctx.Set("userID", key.UserID)
+	ctx.Set(apikey.ContextAuthMethod, apikey.AuthMethodAPIKey)
+	ctx.Set(apikey.ContextScopes, key.Scopes)
+	if key.ExpiresAt.Valid {
+		ctx.Set("authExpiresAt", key.ExpiresAt.Time)
+	}
+
+	ctx.Next()
+}
+
+// subprotocolToken extracts the token from "Sec-WebSocket-Protocol: bearer,
+// <token>".
+func subprotocolToken(r *http.Request) (string, bool) {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+
+	for i, p := range protocols {
+		if p == WSSubprotocolBearer && i+1 < len(protocols) && protocols[i+1] != "" {
+			return protocols[i+1], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeSessions struct {
+	active bool
+	err    error
+}
+
+func (f fakeSessions) Touch(ctx context.Context, sessionID, userID int64) (bool, error) {
+	return f.active, f.err
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	keys := newTestKeyManager(key)
+	tokens := newTestTokenManager(keys, testIssuer, testAudience, time.Minute)
+
+	access, err := tokens.GenerateAccessToken(1, 2, "user")
+	if err != nil {
+		t.Fatalf("generate access token: %v", err)
+	}
+	refresh, _, err := tokens.GenerateRefreshToken(1, 2)
+	if err != nil {
+		t.Fatalf("generate refresh token: %v", err)
+	}
+	expired, err := newTestTokenManager(keys, testIssuer, testAudience, -time.Minute).GenerateAccessToken(1, 2, "user")
+	if err != nil {
+		t.Fatalf("generate expired token: %v", err)
+	}
+	wrongAudience, err := newTestTokenManager(keys, testIssuer, "another-api", time.Minute).GenerateAccessToken(1, 2, "user")
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	unknownKid, err := newTestTokenManager(newTestKeyManager(newTestKey(t)), testIssuer, testAudience, time.Minute).GenerateAccessToken(1, 2, "user")
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	noSession := validClaims()
+	noSession.SessionID = 0
+
+	active := fakeSessions{active: true}
+
+	tests := []struct {
+		name       string
+		header     string
+		sessions   fakeSessions
+		wantStatus int
+		wantError  string
+	}{
+		{"valid token", "Bearer " + access, active, http.StatusOK, ""},
+		{"missing header", "", active, http.StatusUnauthorized, "authorization header missing"},
+		{"not a bearer token", "Basic " + access, active, http.StatusUnauthorized, "invalid authorization header"},
+		{"empty bearer token", "Bearer  ", active, http.StatusUnauthorized, "token missing"},
+		{"wrong token use", "Bearer " + refresh, active, http.StatusUnauthorized, ErrWrongTokenUse.Error()},
+		{"wrong audience", "Bearer " + wrongAudience, active, http.StatusUnauthorized, ErrInvalidToken.Error()},
+		{"expired token", "Bearer " + expired, active, http.StatusUnauthorized, ErrInvalidToken.Error()},
+		{"unknown kid", "Bearer " + unknownKid, active, http.StatusUnauthorized, ErrInvalidToken.Error()},
+		{"missing sid", "Bearer " + signClaims(t, key, noSession), active, http.StatusUnauthorized, ErrInvalidToken.Error()},
+		{"revoked session", "Bearer " + access, fakeSessions{active: false}, http.StatusUnauthorized, "session has been revoked"},
+		{"session lookup fails", "Bearer " + access, fakeSessions{err: errors.New("db error")}, http.StatusInternalServerError, "db error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", AuthMiddleware(tokens, tt.sessions, nil), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{"user_id": ctx.GetInt64("userID")})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			var body struct {
+				UserID int64  `json:"user_id"`
+				Error  string `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if body.Error != tt.wantError {
+				t.Fatalf("error = %q, want %q", body.Error, tt.wantError)
+			}
+			if tt.wantStatus == http.StatusOK && body.UserID != 1 {
+				t.Fatalf("user_id = %d, want 1", body.UserID)
+			}
+		})
+	}
+}
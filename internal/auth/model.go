@@ -1,18 +1,154 @@
 package auth
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"database/sql"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
 
 type LoginInput struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordInput struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 }
 
+// LoginResponse carries either the token pair or, for accounts with two-factor
+// authentication, a challenge token to be exchanged at /login/2fa.
+type LoginResponse struct {
+	*TokenResponse
+	MFARequired    bool   `json:"mfa_required,omitempty"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
+}
+
+type MFALoginInput struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+type TOTPCodeInput struct {
+	Code string `json:"code"`
+}
+
+type TOTPDisableInput struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type TOTPEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
 type Claims struct {
-	UserID int64 `json:"user_id"`
+	UserID    int64  `json:"user_id"`
+	SessionID int64  `json:"sid"`
+	TokenUse  string `json:"token_use"`
+	Role      string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
+
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	SessionID int64
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	RevokedAt sql.NullTime
+	CreatedAt time.Time
+}
+
+type TOTP struct {
+	UserID       int64
+	Secret       string
+	ConfirmedAt  sql.NullTime
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+type PasswordResetToken struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type SigningKey struct {
+	KID        string
+	Algorithm  string
+	PrivateKey string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
+
+type AccountLockout struct {
+	ID          int64
+	Scope       string
+	UserID      sql.NullInt64
+	Email       string
+	IPAddress   string
+	Failures    int
+	LockedUntil time.Time
+	CreatedAt   time.Time
+}
+
+// WSTicket is a single-use credential for opening a WebSocket from a browser,
+// which cannot send an Authorization header with the handshake.
+type WSTicket struct {
+	TicketHash    string
+	UserID        int64
+	SessionID     int64
+	Role          string
+	AuthExpiresAt time.Time
+	ExpiresAt     time.Time
+}
+
+type WSTicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+	"github.com/vladopadikk/go-chat/internal/mail"
+	"github.com/vladopadikk/go-chat/internal/session"
+)
+
+var ErrInvalidCurrentPassword = errors.New("current password is incorrect")
+var ErrSamePassword = errors.New("new password must differ from the current one")
+
+// ChangePassword replaces the password of a signed-in user. Every session,
+// and with it every access and refresh token issued so far, is revoked along
+// with the user's API keys and open WebSocket connections; the caller gets a
+// fresh token pair for a new session so it stays signed in.
+func (s *Service) ChangePassword(ctx context.Context, userID int64, input ChangePasswordInput, client session.ClientInfo) (TokenResponse, error) {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if u == nil {
+		return TokenResponse{}, ErrInvalidCredentials
+	}
+
+	if err := s.checkLoginThrottle(ctx, u.Email, client.IPAddress); err != nil {
+		return TokenResponse{}, err
+	}
+
+	if !s.checkPassword(u, input.CurrentPassword) {
+		if err := s.recordLoginFailure(ctx, u.ID, u.Email, client.IPAddress); err != nil {
+			return TokenResponse{}, err
+		}
+		return TokenResponse{}, ErrInvalidCurrentPassword
+	}
+
+	if input.NewPassword == input.CurrentPassword {
+		return TokenResponse{}, ErrSamePassword
+	}
+	if err := s.policy.Validate(input.NewPassword, u.Username, u.Email); err != nil {
+		return TokenResponse{}, err
+	}
+
+	hashedPass, err := s.hasher.Hash(input.NewPassword)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	if err := s.repo.UpdatePasswordHash(ctx, tx, u.ID, hashedPass); err != nil {
+		return TokenResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if err := s.repo.MarkPasswordResetTokensUsed(ctx, tx, u.ID, now); err != nil {
+		return TokenResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if err := s.revokeCredentials(ctx, tx, u.ID, now); err != nil {
+		return TokenResponse{}, err
+	}
+
+	tokens, err := s.createSession(ctx, tx, u.ID, client)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return TokenResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	s.disconnector.DisconnectUser(u.ID)
+
+	if err := s.resetLoginFailures(ctx, u.Email); err != nil {
+		return TokenResponse{}, err
+	}
+
+	msg := mail.Message{
+		To:      u.Email,
+		Subject: "Your password was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe password of your account was just changed. All other devices were signed out and your API keys were revoked.\n\nIf this was not you, reset your password immediately.\n",
+			u.Username,
+		),
+	}
+	go func() {
+		if err := s.mailer.Send(context.Background(), msg); err != nil {
+			log.Printf("failed to send password change notice to user %d: %v", u.ID, err)
+		}
+	}()
+
+	return tokens, nil
+}
+
+// revokeCredentials revokes every session and API key of the user after the
+// password has changed. Callers close the user's live connections once the
+// transaction has committed.
+func (s *Service) revokeCredentials(ctx context.Context, exec database.Executor, userID int64, now time.Time) error {
+	if err := s.sessionRepo.RevokeAllByUserID(ctx, exec, userID, 0, now); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if err := s.apiKeyRepo.RevokeAllByUserID(ctx, exec, userID, now); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return nil
+}
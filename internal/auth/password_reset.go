@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/mail"
+	"github.com/vladopadikk/go-chat/internal/validation"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// ForgotPassword emails a single-use reset link if the email belongs to an
+// account. All of the work happens in the background, so neither the result
+// nor the response time reveals which emails are registered.
+func (s *Service) ForgotPassword(ctx context.Context, input ForgotPasswordInput) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := s.sendPasswordReset(ctx, validation.NormalizeEmail(input.Email)); err != nil {
+			log.Printf("failed to send password reset email: %v", err)
+		}
+	}()
+}
+
+// sendPasswordReset replaces the account's reset token and emails the new
+// one, at most once per PasswordResetCooldown.
+func (s *Service) sendPasswordReset(ctx context.Context, email string) error {
+	u, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if u == nil {
+		return nil
+	}
+
+	token, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	claimed, err := s.repo.ClaimPasswordResetSend(ctx, tx, u.ID, now, now.Add(-s.cfg.PasswordResetCooldown))
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if !claimed {
+		return nil
+	}
+
+	if err := s.repo.MarkPasswordResetTokensUsed(ctx, tx, u.ID, now); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	err = s.repo.CreatePasswordResetToken(ctx, tx, PasswordResetToken{
+		UserID:    u.ID,
+		TokenHash: HashToken(token),
+		ExpiresAt: now.Add(s.cfg.PasswordResetTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	link := s.cfg.AppBaseURL + "/reset-password?token=" + url.QueryEscape(token)
+	msg := mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to choose a new password. It expires in %s and works once.\n\n%s\n\nIf you did not ask for this, you can ignore this email.\n",
+			u.Username, s.cfg.PasswordResetTTL, link,
+		),
+	}
+
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("user %d: %w", u.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and signs the user
+// out of every session, revoking their API keys and WebSocket connections.
+func (s *Service) ResetPassword(ctx context.Context, input ResetPasswordInput) error {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	token, err := s.repo.GetPasswordResetTokenForUpdate(ctx, tx, HashToken(input.Token))
+	if err == sql.ErrNoRows {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	now := time.Now()
+
+	if token.UsedAt.Valid || now.After(token.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	u, err := s.repo.GetByID(ctx, token.UserID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if u == nil {
+		return ErrInvalidResetToken
+	}
+
+	if err := s.policy.Validate(input.NewPassword, u.Username, u.Email); err != nil {
+		return err
+	}
+
+	hashedPass, err := s.hasher.Hash(input.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdatePasswordHash(ctx, tx, token.UserID, hashedPass); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if err := s.repo.MarkPasswordResetTokensUsed(ctx, tx, token.UserID, now); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if err := s.revokeCredentials(ctx, tx, token.UserID, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	s.disconnector.DisconnectUser(token.UserID)
+
+	return nil
+}
@@ -3,7 +3,9 @@ package auth
 import (
 	"context"
 	"database/sql"
+	"time"
 
+	"github.com/vladopadikk/go-chat/internal/database"
 	"github.com/vladopadikk/go-chat/internal/user"
 )
 
@@ -17,9 +19,9 @@ func NewRepository(db *sql.DB) *Repository {
 
 func (r *Repository) GetByEmail(ctx context.Context, email string) (*user.User, error) {
 	query := `
-			SELECT id, username, email, password_hash, created_at 
+			SELECT id, username, email, password_hash, role, suspended_at, created_at
 			FROM users
-			WHERE email = $1; 
+			WHERE LOWER(email) = LOWER($1);
 	`
 
 	user := &user.User{}
@@ -28,6 +30,8 @@ func (r *Repository) GetByEmail(ctx context.Context, email string) (*user.User,
 		&user.Username,
 		&user.Email,
 		&user.PasswordHash,
+		&user.Role,
+		&user.SuspendedAt,
 		&user.CreatedAt,
 	)
 
@@ -37,3 +41,438 @@ func (r *Repository) GetByEmail(ctx context.Context, email string) (*user.User,
 
 	return user, err
 }
+
+func (r *Repository) CreateRefreshToken(ctx context.Context, exec database.Executor, token RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, session_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := exec.ExecContext(ctx, query, token.UserID, token.SessionID, token.FamilyID, token.TokenHash, token.ExpiresAt)
+	return err
+}
+
+func (r *Repository) GetRefreshTokenForUpdate(ctx context.Context, exec database.Executor, tokenHash string) (RefreshToken, error) {
+	query := `
+		SELECT id, user_id, session_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE;
+	`
+	var token RefreshToken
+	err := exec.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.SessionID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	return token, err
+}
+
+func (r *Repository) MarkRefreshTokenUsed(ctx context.Context, exec database.Executor, id int64, usedAt time.Time) error {
+	query := `
+		UPDATE refresh_tokens
+		SET used_at = $2
+		WHERE id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, id, usedAt)
+	return err
+}
+
+func (r *Repository) RevokeRefreshTokenFamily(ctx context.Context, exec database.Executor, familyID string, revokedAt time.Time) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2
+		WHERE family_id = $1 AND revoked_at IS NULL;
+	`
+	_, err := exec.ExecContext(ctx, query, familyID, revokedAt)
+	return err
+}
+
+func (r *Repository) CreateSigningKey(ctx context.Context, exec database.Executor, key SigningKey) error {
+	query := `
+		INSERT INTO signing_keys (kid, algorithm, private_key, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := exec.ExecContext(ctx, query, key.KID, key.Algorithm, key.PrivateKey, key.CreatedAt, key.ExpiresAt)
+	return err
+}
+
+func (r *Repository) GetSigningKeys(ctx context.Context, exec database.Executor, now time.Time) ([]SigningKey, error) {
+	query := `
+		SELECT kid, algorithm, private_key, created_at, expires_at
+		FROM signing_keys
+		WHERE expires_at > $1
+		ORDER BY created_at DESC;
+	`
+	rows, err := exec.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []SigningKey
+	for rows.Next() {
+		var key SigningKey
+		if err := rows.Scan(&key.KID, &key.Algorithm, &key.PrivateKey, &key.CreatedAt, &key.ExpiresAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *Repository) DeleteExpiredSigningKeys(ctx context.Context, exec database.Executor, now time.Time) error {
+	query := `
+		DELETE FROM signing_keys
+		WHERE expires_at <= $1;
+	`
+	_, err := exec.ExecContext(ctx, query, now)
+	return err
+}
+
+// GetLockedUntil returns the latest lockout expiry among the given throttle
+// keys that is still in the future.
+func (r *Repository) GetLockedUntil(ctx context.Context, exec database.Executor, keys []string, now time.Time) (sql.NullTime, error) {
+	query := `
+		SELECT MAX(locked_until)
+		FROM login_failures
+		WHERE key = ANY($1) AND locked_until > $2;
+	`
+	var lockedUntil sql.NullTime
+	err := exec.QueryRowContext(ctx, query, keys, now).Scan(&lockedUntil)
+	return lockedUntil, err
+}
+
+// IncrementLoginFailures bumps the failure counter for a throttle key,
+// starting over when the previous failure or lockout is older than the window.
+func (r *Repository) IncrementLoginFailures(ctx context.Context, exec database.Executor, key string, now, windowStart time.Time) (int, error) {
+	query := `
+		INSERT INTO login_failures (key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN GREATEST(login_failures.last_failure_at, COALESCE(login_failures.locked_until, login_failures.last_failure_at)) < $3
+				THEN 1
+				ELSE login_failures.failures + 1
+			END,
+			last_failure_at = $2
+		RETURNING failures;
+	`
+	var failures int
+	err := exec.QueryRowContext(ctx, query, key, now, windowStart).Scan(&failures)
+	return failures, err
+}
+
+func (r *Repository) LockLoginKey(ctx context.Context, exec database.Executor, key string, lockedUntil time.Time) error {
+	query := `
+		UPDATE login_failures
+		SET locked_until = $2
+		WHERE key = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, key, lockedUntil)
+	return err
+}
+
+func (r *Repository) ResetLoginFailures(ctx context.Context, exec database.Executor, key string) error {
+	query := `
+		DELETE FROM login_failures
+		WHERE key = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, key)
+	return err
+}
+
+func (r *Repository) CreateLockout(ctx context.Context, exec database.Executor, lockout AccountLockout) error {
+	query := `
+		INSERT INTO account_lockouts (scope, user_id, email, ip_address, failures, locked_until)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := exec.ExecContext(ctx, query,
+		lockout.Scope,
+		lockout.UserID,
+		lockout.Email,
+		lockout.IPAddress,
+		lockout.Failures,
+		lockout.LockedUntil,
+	)
+	return err
+}
+
+func (r *Repository) UpdatePasswordHash(ctx context.Context, exec database.Executor, userID int64, passwordHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $2
+		WHERE id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, userID, passwordHash)
+	return err
+}
+
+func (r *Repository) CreatePasswordResetToken(ctx context.Context, exec database.Executor, token PasswordResetToken) error {
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`
+	_, err := exec.ExecContext(ctx, query, token.UserID, token.TokenHash, token.ExpiresAt)
+	return err
+}
+
+func (r *Repository) GetPasswordResetTokenForUpdate(ctx context.Context, exec database.Executor, tokenHash string) (PasswordResetToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, used_at, created_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1
+		FOR UPDATE;
+	`
+	var token PasswordResetToken
+	err := exec.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	return token, err
+}
+
+// MarkPasswordResetTokensUsed consumes every outstanding reset token of the
+// user, so only the most recent link and only one use of it ever works.
+// ClaimPasswordResetSend records that a reset email is being sent, unless
+// the previous one went out after notBefore.
+func (r *Repository) ClaimPasswordResetSend(ctx context.Context, exec database.Executor, id int64, sentAt, notBefore time.Time) (bool, error) {
+	query := `
+		UPDATE users
+		SET password_reset_sent_at = $2
+		WHERE id = $1
+			AND (password_reset_sent_at IS NULL OR password_reset_sent_at <= $3);
+	`
+	res, err := exec.ExecContext(ctx, query, id, sentAt, notBefore)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *Repository) MarkPasswordResetTokensUsed(ctx context.Context, exec database.Executor, userID int64, usedAt time.Time) error {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = $2
+		WHERE user_id = $1 AND used_at IS NULL;
+	`
+	_, err := exec.ExecContext(ctx, query, userID, usedAt)
+	return err
+}
+
+func (r *Repository) GetByID(ctx context.Context, id int64) (*user.User, error) {
+	query := `
+			SELECT id, username, email, password_hash, role, suspended_at, created_at
+			FROM users
+			WHERE id = $1;
+	`
+
+	user := &user.User{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Role,
+		&user.SuspendedAt,
+		&user.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return user, err
+}
+
+func (r *Repository) GetUserRole(ctx context.Context, exec database.Executor, userID int64) (string, error) {
+	query := `
+		SELECT role
+		FROM users
+		WHERE id = $1;
+	`
+	var role string
+	err := exec.QueryRowContext(ctx, query, userID).Scan(&role)
+	return role, err
+}
+
+func (r *Repository) IsTOTPEnabled(ctx context.Context, exec database.Executor, userID int64) (bool, error) {
+	query := `
+		SELECT confirmed_at IS NOT NULL
+		FROM user_totp
+		WHERE user_id = $1;
+	`
+	var enabled bool
+	err := exec.QueryRowContext(ctx, query, userID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return enabled, err
+}
+
+func (r *Repository) GetTOTPForUpdate(ctx context.Context, exec database.Executor, userID int64) (TOTP, error) {
+	query := `
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at
+		FROM user_totp
+		WHERE user_id = $1
+		FOR UPDATE;
+	`
+	var totp TOTP
+	err := exec.QueryRowContext(ctx, query, userID).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.ConfirmedAt,
+		&totp.LastUsedStep,
+		&totp.CreatedAt,
+	)
+	return totp, err
+}
+
+// SavePendingTOTP stores a new unconfirmed secret, replacing an earlier
+// enrolment that was never confirmed.
+func (r *Repository) SavePendingTOTP(ctx context.Context, exec database.Executor, userID int64, secret string) (bool, error) {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			last_used_step = 0,
+			created_at = NOW()
+		WHERE user_totp.confirmed_at IS NULL;
+	`
+	res, err := exec.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *Repository) ConfirmTOTP(ctx context.Context, exec database.Executor, userID int64, confirmedAt time.Time, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET confirmed_at = $2, last_used_step = $3
+		WHERE user_id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, userID, confirmedAt, step)
+	return err
+}
+
+func (r *Repository) UpdateTOTPStep(ctx context.Context, exec database.Executor, userID, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, userID, step)
+	return err
+}
+
+func (r *Repository) DeleteTOTP(ctx context.Context, exec database.Executor, userID int64) error {
+	query := `
+		DELETE FROM user_totp
+		WHERE user_id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (r *Repository) DeleteRecoveryCodes(ctx context.Context, exec database.Executor, userID int64) error {
+	query := `
+		DELETE FROM recovery_codes
+		WHERE user_id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (r *Repository) CreateRecoveryCode(ctx context.Context, exec database.Executor, userID int64, codeHash string) error {
+	query := `
+		INSERT INTO recovery_codes (user_id, code_hash)
+		VALUES ($1, $2)
+	`
+	_, err := exec.ExecContext(ctx, query, userID, codeHash)
+	return err
+}
+
+func (r *Repository) UseRecoveryCode(ctx context.Context, exec database.Executor, userID int64, codeHash string, usedAt time.Time) (bool, error) {
+	query := `
+		UPDATE recovery_codes
+		SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+	`
+	res, err := exec.ExecContext(ctx, query, userID, codeHash, usedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *Repository) CreateWSTicket(ctx context.Context, exec database.Executor, ticket WSTicket) error {
+	query := `
+		INSERT INTO ws_tickets (ticket_hash, user_id, session_id, role, auth_expires_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`
+	_, err := exec.ExecContext(ctx, query,
+		ticket.TicketHash,
+		ticket.UserID,
+		ticket.SessionID,
+		ticket.Role,
+		ticket.AuthExpiresAt,
+		ticket.ExpiresAt,
+	)
+	return err
+}
+
+// ConsumeWSTicket deletes and returns an unexpired ticket, so that each one
+// can be redeemed only once.
+func (r *Repository) ConsumeWSTicket(ctx context.Context, exec database.Executor, ticketHash string, now time.Time) (WSTicket, error) {
+	query := `
+		DELETE FROM ws_tickets
+		WHERE ticket_hash = $1 AND expires_at > $2
+		RETURNING ticket_hash, user_id, session_id, role, auth_expires_at, expires_at;
+	`
+	var ticket WSTicket
+	err := exec.QueryRowContext(ctx, query, ticketHash, now).Scan(
+		&ticket.TicketHash,
+		&ticket.UserID,
+		&ticket.SessionID,
+		&ticket.Role,
+		&ticket.AuthExpiresAt,
+		&ticket.ExpiresAt,
+	)
+	return ticket, err
+}
+
+func (r *Repository) DeleteExpiredWSTickets(ctx context.Context, exec database.Executor, now time.Time) error {
+	query := `
+		DELETE FROM ws_tickets
+		WHERE expires_at <= $1;
+	`
+	_, err := exec.ExecContext(ctx, query, now)
+	return err
+}
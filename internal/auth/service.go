@@ -2,52 +2,314 @@ package auth
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/vladopadikk/go-chat/internal/apikey"
 	"github.com/vladopadikk/go-chat/internal/config"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/vladopadikk/go-chat/internal/database"
+	"github.com/vladopadikk/go-chat/internal/mail"
+	"github.com/vladopadikk/go-chat/internal/password"
+	"github.com/vladopadikk/go-chat/internal/session"
+	"github.com/vladopadikk/go-chat/internal/user"
+	"github.com/vladopadikk/go-chat/internal/validation"
 )
 
-var ErrUserNotFound = errors.New("user not found")
-var ErrInvalidPassword = errors.New("invalid password")
+var ErrInvalidCredentials = errors.New("invalid email or password")
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+var ErrAccountSuspended = errors.New("account is suspended")
+
+// Disconnector closes the live connections of a user.
+type Disconnector interface {
+	DisconnectUser(userID int64)
+}
 
 type Service struct {
-	repo *Repository
-	cfg  *config.Config
+	repo         *Repository
+	sessionRepo  *session.Repository
+	apiKeyRepo   *apikey.Repository
+	keys         *KeyManager
+	tokens       *TokenManager
+	hasher       *password.Hasher
+	policy       *password.Policy
+	mailer       mail.Mailer
+	disconnector Disconnector
+	cfg          *config.Config
 }
 
-func NewService(repo *Repository, cfg *config.Config) *Service {
-	return &Service{repo, cfg}
+func NewService(repo *Repository, sessionRepo *session.Repository, apiKeyRepo *apikey.Repository, keys *KeyManager, tokens *TokenManager, hasher *password.Hasher, policy *password.Policy, mailer mail.Mailer, disconnector Disconnector, cfg *config.Config) *Service {
+	return &Service{repo, sessionRepo, apiKeyRepo, keys, tokens, hasher, policy, mailer, disconnector, cfg}
 
 }
 
-func (s *Service) Login(ctx context.Context, loginIn LoginInput) (TokenResponse, error) {
+// Login checks the password. Accounts with two-factor authentication get a
+// challenge token instead of tokens and finish with LoginMFA.
+func (s *Service) Login(ctx context.Context, loginIn LoginInput, client session.ClientInfo) (LoginResponse, error) {
+	loginIn.Email = validation.NormalizeEmail(loginIn.Email)
+
+	if err := s.checkLoginThrottle(ctx, loginIn.Email, client.IPAddress); err != nil {
+		return LoginResponse{}, err
+	}
+
 	u, err := s.repo.GetByEmail(ctx, loginIn.Email)
 	if err != nil {
-		return TokenResponse{}, fmt.Errorf("db error: %w", err)
+		return LoginResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	if u == nil {
+		s.hasher.VerifyDummy(loginIn.Password)
+		if err := s.recordLoginFailure(ctx, 0, loginIn.Email, client.IPAddress); err != nil {
+			return LoginResponse{}, err
+		}
+		return LoginResponse{}, ErrInvalidCredentials
+	}
+
+	if !s.checkPassword(u, loginIn.Password) {
+		if err := s.recordLoginFailure(ctx, u.ID, loginIn.Email, client.IPAddress); err != nil {
+			return LoginResponse{}, err
+		}
+		return LoginResponse{}, ErrInvalidCredentials
+	}
+
+	if u.SuspendedAt.Valid {
+		return LoginResponse{}, ErrAccountSuspended
+	}
+
+	s.upgradePasswordHash(ctx, u, loginIn.Password)
+
+	mfaEnabled, err := s.isTOTPEnabled(ctx, u.ID)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	if mfaEnabled {
+		challenge, err := s.tokens.GenerateChallengeToken(u.ID)
+		if err != nil {
+			return LoginResponse{}, err
+		}
+		return LoginResponse{MFARequired: true, ChallengeToken: challenge}, nil
+	}
+
+	if err := s.resetLoginFailures(ctx, loginIn.Email); err != nil {
+		return LoginResponse{}, err
+	}
+
+	tokens, err := s.startSession(ctx, u.ID, client)
+	if err != nil {
+		return LoginResponse{}, err
 	}
+	return LoginResponse{TokenResponse: &tokens}, nil
+}
 
+// IssueSession signs the user in without a password, for callers that have
+// authenticated them by other means such as an external identity provider.
+// Like Login, accounts with two-factor authentication get a challenge token
+// and finish with LoginMFA.
+func (s *Service) IssueSession(ctx context.Context, userID int64, client session.ClientInfo) (LoginResponse, error) {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return LoginResponse{}, fmt.Errorf("db error: %w", err)
+	}
 	if u == nil {
-		return TokenResponse{}, ErrUserNotFound
+		return LoginResponse{}, ErrInvalidCredentials
+	}
+	if u.SuspendedAt.Valid {
+		return LoginResponse{}, ErrAccountSuspended
+	}
+
+	mfaEnabled, err := s.isTOTPEnabled(ctx, u.ID)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	if mfaEnabled {
+		challenge, err := s.tokens.GenerateChallengeToken(u.ID)
+		if err != nil {
+			return LoginResponse{}, err
+		}
+		return LoginResponse{MFARequired: true, ChallengeToken: challenge}, nil
+	}
+
+	tokens, err := s.startSession(ctx, userID, client)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	return LoginResponse{TokenResponse: &tokens}, nil
+}
+
+func (s *Service) JWKS() JWKSResponse {
+	return s.keys.JWKS()
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token
+// can be used exactly once; presenting one that was already rotated revokes
+// the whole family it belongs to together with its session.
+func (s *Service) Refresh(ctx context.Context, refreshIn RefreshInput) (TokenResponse, error) {
+	claims, err := s.tokens.ParseRefreshToken(ctx, refreshIn.RefreshToken)
+	if err != nil {
+		return TokenResponse{}, ErrInvalidRefreshToken
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	stored, err := s.repo.GetRefreshTokenForUpdate(ctx, tx, HashToken(refreshIn.RefreshToken))
+	if err == sql.ErrNoRows {
+		return TokenResponse{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	if stored.UserID != claims.UserID || stored.SessionID != claims.SessionID || stored.RevokedAt.Valid {
+		return TokenResponse{}, ErrInvalidRefreshToken
+	}
+
+	now := time.Now()
+
+	if stored.UsedAt.Valid {
+		if err := s.repo.RevokeRefreshTokenFamily(ctx, tx, stored.FamilyID, now); err != nil {
+			return TokenResponse{}, fmt.Errorf("db error: %w", err)
+		}
+		if _, err := s.sessionRepo.Revoke(ctx, tx, stored.SessionID, stored.UserID, now); err != nil {
+			return TokenResponse{}, fmt.Errorf("db error: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return TokenResponse{}, fmt.Errorf("commit tx: %w", err)
+		}
+		return TokenResponse{}, ErrRefreshTokenReused
+	}
+
+	if now.After(stored.ExpiresAt) {
+		return TokenResponse{}, ErrInvalidRefreshToken
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(loginIn.Password))
+	active, err := s.sessionRepo.Touch(ctx, tx, stored.SessionID, stored.UserID, now)
 	if err != nil {
-		return TokenResponse{}, ErrInvalidPassword
+		return TokenResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if !active {
+		return TokenResponse{}, ErrInvalidRefreshToken
+	}
+
+	if err := s.repo.MarkRefreshTokenUsed(ctx, tx, stored.ID, now); err != nil {
+		return TokenResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	tokens, err := s.issueTokens(ctx, tx, stored.UserID, stored.SessionID, stored.FamilyID)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return TokenResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// startSession opens a new device session for the user and issues the first
+// token pair of its refresh token family.
+func (s *Service) startSession(ctx context.Context, userID int64, client session.ClientInfo) (TokenResponse, error) {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("transaction error: %w", err)
 	}
+	defer tx.Rollback()
 
-	accessToken, err := GenerateAccessToken(u.ID, s.cfg.JWTSecret)
+	tokens, err := s.createSession(ctx, tx, userID, client)
 	if err != nil {
 		return TokenResponse{}, err
 	}
-	refreshToken, err := GenerateRefreshToken(u.ID, s.cfg.JWTSecret)
+
+	if err := tx.Commit(); err != nil {
+		return TokenResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return tokens, nil
+}
+
+func (s *Service) createSession(ctx context.Context, exec database.Executor, userID int64, client session.ClientInfo) (TokenResponse, error) {
+	sess, err := s.sessionRepo.Create(ctx, exec, userID, client)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	familyID, err := newTokenID()
 	if err != nil {
 		return TokenResponse{}, err
 	}
 
+	return s.issueTokens(ctx, exec, userID, sess.ID, familyID)
+}
+
+func (s *Service) issueTokens(ctx context.Context, exec database.Executor, userID, sessionID int64, familyID string) (TokenResponse, error) {
+	role, err := s.repo.GetUserRole(ctx, exec, userID)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	accessToken, err := s.tokens.GenerateAccessToken(userID, sessionID, role)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	refreshToken, refreshExpiresAt, err := s.tokens.GenerateRefreshToken(userID, sessionID)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	err = s.repo.CreateRefreshToken(ctx, exec, RefreshToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		FamilyID:  familyID,
+		TokenHash: HashToken(refreshToken),
+		ExpiresAt: refreshExpiresAt,
+	})
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
 	return TokenResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 	}, nil
 }
+
+// checkPassword verifies a password against the stored hash. Accounts without
+// a local password and unreadable hashes never match.
+func (s *Service) checkPassword(u *user.User, pw string) bool {
+	if u.PasswordHash == "" {
+		s.hasher.VerifyDummy(pw)
+		return false
+	}
+
+	ok, err := s.hasher.Verify(pw, u.PasswordHash)
+	if err != nil {
+		log.Printf("failed to verify password of user %d: %v", u.ID, err)
+		return false
+	}
+	return ok
+}
+
+// upgradePasswordHash re-hashes a just verified password when its stored hash
+// uses an older algorithm or weaker parameters. Failures only get logged; the
+// next successful login tries again.
+func (s *Service) upgradePasswordHash(ctx context.Context, u *user.User, pw string) {
+	if !s.hasher.NeedsRehash(u.PasswordHash) {
+		return
+	}
+
+	hash, err := s.hasher.Hash(pw)
+	if err != nil {
+		log.Printf("failed to rehash password of user %d: %v", u.ID, err)
+		return
+	}
+	if err := s.repo.UpdatePasswordHash(ctx, s.repo.db, u.ID, hash); err != nil {
+		log.Printf("failed to store rehashed password of user %d: %v", u.ID, err)
+	}
+}
@@ -1,33 +1,176 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/vladopadikk/go-chat/internal/config"
 )
 
-func GenerateAccessToken(userID int64, secret string) (string, error) {
-	claims := Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+const (
+	TokenUseAccess       = "access"
+	TokenUseRefresh      = "refresh"
+	TokenUseMFAChallenge = "mfa_challenge"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+var ErrWrongTokenUse = errors.New("invalid token type")
+
+type TokenManager struct {
+	keys         *KeyManager
+	issuer       string
+	audience     string
+	accessTTL    time.Duration
+	refreshTTL   time.Duration
+	challengeTTL time.Duration
+}
+
+func NewTokenManager(keys *KeyManager, cfg *config.Config) *TokenManager {
+	return &TokenManager{
+		keys:         keys,
+		issuer:       cfg.JWTIssuer,
+		audience:     cfg.JWTAudience,
+		accessTTL:    cfg.AccessTokenTTL,
+		refreshTTL:   cfg.RefreshTokenTTL,
+		challengeTTL: cfg.MFAChallengeTTL,
 	}
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+// GenerateAccessToken carries the user's global role so that role checks do
+// not need a database lookup.
+func (m *TokenManager) GenerateAccessToken(userID, sessionID int64, role string) (string, error) {
+	token, _, err := m.generate(userID, sessionID, role, TokenUseAccess, m.accessTTL)
+	return token, err
 }
 
-func GenerateRefreshToken(userID int64, secret string) (string, error) {
+// GenerateRefreshToken returns the signed token together with its expiry so
+// callers can persist it.
+func (m *TokenManager) GenerateRefreshToken(userID, sessionID int64) (string, time.Time, error) {
+	return m.generate(userID, sessionID, "", TokenUseRefresh, m.refreshTTL)
+}
+
+// GenerateChallengeToken proves that the password step of a two-factor login
+// succeeded. It is not bound to a session.
+func (m *TokenManager) GenerateChallengeToken(userID int64) (string, error) {
+	token, _, err := m.generate(userID, 0, "", TokenUseMFAChallenge, m.challengeTTL)
+	return token, err
+}
+
+func (m *TokenManager) ParseAccessToken(ctx context.Context, tokenString string) (*Claims, error) {
+	return m.parse(ctx, tokenString, TokenUseAccess)
+}
+
+func (m *TokenManager) ParseRefreshToken(ctx context.Context, tokenString string) (*Claims, error) {
+	return m.parse(ctx, tokenString, TokenUseRefresh)
+}
+
+func (m *TokenManager) ParseChallengeToken(ctx context.Context, tokenString string) (*Claims, error) {
+	return m.parse(ctx, tokenString, TokenUseMFAChallenge)
+}
+
+func (m *TokenManager) generate(userID, sessionID int64, role, use string, ttl time.Duration) (string, time.Time, error) {
+	key, err := m.keys.signingKey()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
 	claims := Claims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenUse:  use,
+		Role:      role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        tokenID,
+			Issuer:    m.issuer,
+			Audience:  jwt.ClaimStrings{m.audience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+
+	signed, err := token.SignedString(key.private)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+func (m *TokenManager) parse(ctx context.Context, tokenString, use string) (*Claims, error) {
+	claims := &Claims{}
+
+	parsedToken, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := m.keys.verificationKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != key.method.Alg() {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		return key.private.Public(), nil
+	},
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithAudience(m.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil || !parsedToken.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.TokenUse != use {
+		return nil, ErrWrongTokenUse
+	}
+
+	if claims.UserID == 0 || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if use != TokenUseMFAChallenge && claims.SessionID == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// HashToken returns the hex-encoded SHA-256 of a token so that raw tokens
+// never have to be stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// newOpaqueToken returns a random URL-safe token for links sent by email.
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vladopadikk/go-chat/internal/config"
+)
+
+const (
+	testIssuer   = "go-chat-test"
+	testAudience = "go-chat-test-api"
+)
+
+func newTestKey(t *testing.T) *signingKey {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	kid, err := newTokenID()
+	if err != nil {
+		t.Fatalf("generate kid: %v", err)
+	}
+
+	now := time.Now()
+	return &signingKey{
+		kid:       kid,
+		method:    jwt.SigningMethodEdDSA,
+		private:   private,
+		createdAt: now,
+		expiresAt: now.Add(time.Hour),
+	}
+}
+
+// newTestKeyManager returns a KeyManager holding only key. Its last reload is
+// set to now so that unknown kids never reach the (absent) database.
+func newTestKeyManager(key *signingKey) *KeyManager {
+	return &KeyManager{
+		algorithm:    AlgEdDSA,
+		keys:         map[string]*signingKey{key.kid: key},
+		current:      key,
+		missReloadAt: time.Now(),
+	}
+}
+
+func newTestTokenManager(keys *KeyManager, issuer, audience string, ttl time.Duration) *TokenManager {
+	return NewTokenManager(keys, &config.Config{
+		JWTIssuer:       issuer,
+		JWTAudience:     audience,
+		AccessTokenTTL:  ttl,
+		RefreshTokenTTL: ttl,
+		MFAChallengeTTL: ttl,
+	})
+}
+
+func signClaims(t *testing.T, key *signingKey, claims Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+
+	signed, err := token.SignedString(key.private)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func validClaims() Claims {
+	now := time.Now()
+	return Claims{
+		UserID:    1,
+		SessionID: 2,
+		TokenUse:  TokenUseAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func TestTokenManagerParse(t *testing.T) {
+	key := newTestKey(t)
+	keys := newTestKeyManager(key)
+	tokens := newTestTokenManager(keys, testIssuer, testAudience, time.Minute)
+
+	access, err := tokens.GenerateAccessToken(1, 2, "user")
+	if err != nil {
+		t.Fatalf("generate access token: %v", err)
+	}
+	refresh, _, err := tokens.GenerateRefreshToken(1, 2)
+	if err != nil {
+		t.Fatalf("generate refresh token: %v", err)
+	}
+	challenge, err := tokens.GenerateChallengeToken(1)
+	if err != nil {
+		t.Fatalf("generate challenge token: %v", err)
+	}
+
+	generate := func(tm *TokenManager) string {
+		t.Helper()
+		token, err := tm.GenerateAccessToken(1, 2, "user")
+		if err != nil {
+			t.Fatalf("generate access token: %v", err)
+		}
+		return token
+	}
+
+	otherKeys := newTestKeyManager(newTestKey(t))
+
+	withClaims := func(edit func(*Claims)) string {
+		claims := validClaims()
+		edit(&claims)
+		return signClaims(t, key, claims)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		use     string
+		wantErr error
+	}{
+		{"valid access token", access, TokenUseAccess, nil},
+		{"valid refresh token", refresh, TokenUseRefresh, nil},
+		{"valid challenge token", challenge, TokenUseMFAChallenge, nil},
+		{"refresh token used as access token", refresh, TokenUseAccess, ErrWrongTokenUse},
+		{"access token used as refresh token", access, TokenUseRefresh, ErrWrongTokenUse},
+		{"challenge token used as access token", challenge, TokenUseAccess, ErrWrongTokenUse},
+		{"wrong issuer", generate(newTestTokenManager(keys, "someone-else", testAudience, time.Minute)), TokenUseAccess, ErrInvalidToken},
+		{"wrong audience", generate(newTestTokenManager(keys, testIssuer, "another-api", time.Minute)), TokenUseAccess, ErrInvalidToken},
+		{"expired", generate(newTestTokenManager(keys, testIssuer, testAudience, -time.Minute)), TokenUseAccess, ErrInvalidToken},
+		{"unknown kid", generate(newTestTokenManager(otherKeys, testIssuer, testAudience, time.Minute)), TokenUseAccess, ErrInvalidToken},
+		{"missing expiry", withClaims(func(c *Claims) { c.ExpiresAt = nil }), TokenUseAccess, ErrInvalidToken},
+		{"missing sid", withClaims(func(c *Claims) { c.SessionID = 0 }), TokenUseAccess, ErrInvalidToken},
+		{"missing jti", withClaims(func(c *Claims) { c.ID = "" }), TokenUseAccess, ErrInvalidToken},
+		{"missing user", withClaims(func(c *Claims) { c.UserID = 0 }), TokenUseAccess, ErrInvalidToken},
+		{"tampered signature", access[:len(access)-4] + "AAAA", TokenUseAccess, ErrInvalidToken},
+		{"garbage", "not-a-token", TokenUseAccess, ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tokens.parse(context.Background(), tt.token, tt.use)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && claims.UserID != 1 {
+				t.Fatalf("parse() user = %d, want 1", claims.UserID)
+			}
+		})
+	}
+}
+
+func TestTokenManagerParseExpiredKey(t *testing.T) {
+	key := newTestKey(t)
+	keys := newTestKeyManager(key)
+	tokens := newTestTokenManager(keys, testIssuer, testAudience, time.Minute)
+
+	token, err := tokens.GenerateAccessToken(1, 2, "user")
+	if err != nil {
+		t.Fatalf("generate access token: %v", err)
+	}
+
+	key.expiresAt = time.Now().Add(-time.Second)
+
+	if _, err := tokens.ParseAccessToken(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("ParseAccessToken() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
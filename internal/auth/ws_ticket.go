@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrInvalidWSTicket = errors.New("invalid or expired websocket ticket")
+
+// IssueWSTicket hands out a short-lived ticket for the caller's session. The
+// connection opened with it lives no longer than the access token that was
+// used to ask for it, unless it re-authenticates in-band.
+func (s *Service) IssueWSTicket(ctx context.Context, userID, sessionID int64, role string, authExpiresAt time.Time) (WSTicketResponse, error) {
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return WSTicketResponse{}, err
+	}
+
+	now := time.Now()
+	ticket := WSTicket{
+		TicketHash:    HashToken(raw),
+		UserID:        userID,
+		SessionID:     sessionID,
+		Role:          role,
+		AuthExpiresAt: authExpiresAt,
+		ExpiresAt:     now.Add(s.cfg.WSTicketTTL),
+	}
+
+	if err := s.repo.CreateWSTicket(ctx, s.repo.db, ticket); err != nil {
+		return WSTicketResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	if err := s.repo.DeleteExpiredWSTickets(ctx, s.repo.db, now); err != nil {
+		log.Printf("failed to delete expired websocket tickets: %v", err)
+	}
+
+	return WSTicketResponse{Ticket: raw, ExpiresAt: ticket.ExpiresAt}, nil
+}
+
+// RedeemWSTicket consumes a ticket and checks that its session is still
+// active.
+func (s *Service) RedeemWSTicket(ctx context.Context, raw string) (WSTicket, error) {
+	now := time.Now()
+
+	ticket, err := s.repo.ConsumeWSTicket(ctx, s.repo.db, HashToken(raw), now)
+	if err == sql.ErrNoRows {
+		return WSTicket{}, ErrInvalidWSTicket
+	}
+	if err != nil {
+		return WSTicket{}, fmt.Errorf("db error: %w", err)
+	}
+
+	if !now.Before(ticket.AuthExpiresAt) {
+		return WSTicket{}, ErrInvalidWSTicket
+	}
+
+	active, err := s.sessionRepo.Touch(ctx, s.repo.db, ticket.SessionID, ticket.UserID, now)
+	if err != nil {
+		return WSTicket{}, fmt.Errorf("db error: %w", err)
+	}
+	if !active {
+		return WSTicket{}, ErrInvalidWSTicket
+	}
+
+	return ticket, nil
+}
+
+// Reauthenticate checks an access token presented on an already open
+// WebSocket and returns its user and expiry.
+func (s *Service) Reauthenticate(ctx context.Context, token string) (int64, time.Time, error) {
+	claims, err := s.tokens.ParseAccessToken(ctx, token)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	active, err := s.sessionRepo.Touch(ctx, s.repo.db, claims.SessionID, claims.UserID, time.Now())
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("db error: %w", err)
+	}
+	if !active {
+		return 0, time.Time{}, ErrInvalidToken
+	}
+
+	return claims.UserID, claims.ExpiresAt.Time, nil
+}
@@ -0,0 +1,108 @@
+package block
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service}
+}
+
+func (h *Handler) BlockHandler(ctx *gin.Context) {
+	userID, targetID, ok := currentUserAndTarget(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Block(ctx.Request.Context(), userID, targetID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) UnblockHandler(ctx *gin.Context) {
+	userID, targetID, ok := currentUserAndTarget(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Unblock(ctx.Request.Context(), userID, targetID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) GetBlocksHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	blocks, err := h.service.List(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, blocks)
+}
+
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrCannotBlockSelf):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrBlockNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func currentUserAndTarget(ctx *gin.Context) (int64, int64, bool) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return 0, 0, false
+	}
+
+	targetID, err := strconv.ParseInt(ctx.Param("userID"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return 0, 0, false
+	}
+	return userID, targetID, true
+}
+
+func currentUserID(ctx *gin.Context) (int64, bool) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return 0, false
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func RegisterRoutes(r *gin.RouterGroup, h *Handler) {
+	blocks := r.Group("/blocks")
+	{
+		blocks.GET("", h.GetBlocksHandler)
+		blocks.POST("/:userID", h.BlockHandler)
+		blocks.DELETE("/:userID", h.UnblockHandler)
+	}
+}
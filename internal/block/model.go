@@ -0,0 +1,15 @@
+package block
+
+import "time"
+
+type BlockResponse struct {
+	UserID      int64     `json:"user_id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+	BlockedAt   time.Time `json:"blocked_at"`
+}
+
+type BlockListResponse struct {
+	Blocks []BlockResponse `json:"blocks"`
+}
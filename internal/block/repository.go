@@ -0,0 +1,141 @@
+package block
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db}
+}
+
+func (r *Repository) Create(ctx context.Context, exec database.Executor, blockerID, blockedID int64, createdAt time.Time) error {
+	query := `
+		INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+	`
+	_, err := exec.ExecContext(ctx, query, blockerID, blockedID, createdAt)
+	return err
+}
+
+func (r *Repository) Delete(ctx context.Context, exec database.Executor, blockerID, blockedID int64) (bool, error) {
+	query := `
+		DELETE FROM user_blocks
+		WHERE blocker_id = $1 AND blocked_id = $2;
+	`
+	res, err := exec.ExecContext(ctx, query, blockerID, blockedID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *Repository) List(ctx context.Context, exec database.Executor, blockerID int64) ([]BlockResponse, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC;
+	`
+	rows, err := exec.QueryContext(ctx, query, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := []BlockResponse{}
+	for rows.Next() {
+		var b BlockResponse
+		if err := rows.Scan(&b.UserID, &b.Username, &b.DisplayName, &b.AvatarURL, &b.BlockedAt); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+
+	return blocks, rows.Err()
+}
+
+func (r *Repository) IsBlocked(ctx context.Context, exec database.Executor, blockerID, blockedID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM user_blocks
+			WHERE blocker_id = $1 AND blocked_id = $2
+		);
+	`
+	var blocked bool
+	err := exec.QueryRowContext(ctx, query, blockerID, blockedID).Scan(&blocked)
+	return blocked, err
+}
+
+// GetBlockersInChat returns the members of the chat who have blocked the user.
+func (r *Repository) GetBlockersInChat(ctx context.Context, exec database.Executor, chatID, userID int64) ([]int64, error) {
+	query := `
+		SELECT b.blocker_id
+		FROM user_blocks b
+		JOIN chat_members cm ON cm.user_id = b.blocker_id AND cm.chat_id = $1
+		WHERE b.blocked_id = $2;
+	`
+	rows, err := exec.QueryContext(ctx, query, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blockers []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		blockers = append(blockers, id)
+	}
+
+	return blockers, rows.Err()
+}
+
+func (r *Repository) UserExists(ctx context.Context, exec database.Executor, userID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM users
+			WHERE id = $1 AND deleted_at IS NULL
+		);
+	`
+	var exists bool
+	err := exec.QueryRowContext(ctx, query, userID).Scan(&exists)
+	return exists, err
+}
+
+// DeleteRelationship removes any contact entry, pending contact request and
+// pending group invitation between the two users.
+func (r *Repository) DeleteRelationship(ctx context.Context, exec database.Executor, userA, userB int64) error {
+	queries := []string{
+		`DELETE FROM contacts
+		WHERE (user_id = $1 AND contact_id = $2) OR (user_id = $2 AND contact_id = $1);`,
+		`DELETE FROM contact_requests
+		WHERE (sender_id = $1 AND recipient_id = $2) OR (sender_id = $2 AND recipient_id = $1);`,
+		`DELETE FROM chat_invitations
+		WHERE (inviter_id = $1 AND invitee_id = $2) OR (inviter_id = $2 AND invitee_id = $1);`,
+	}
+	for _, query := range queries {
+		if _, err := exec.ExecContext(ctx, query, userA, userB); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package block
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+var ErrBlockNotFound = errors.New("user is not blocked")
+var ErrCannotBlockSelf = errors.New("you cannot block yourself")
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo}
+}
+
+// Block stops the other user from starting chats with, messaging or inviting
+// the blocker, and ends any contact relationship between the two. Blocking
+// someone twice is not an error.
+func (s *Service) Block(ctx context.Context, blockerID, blockedID int64) error {
+	if blockerID == blockedID {
+		return ErrCannotBlockSelf
+	}
+
+	exists, err := s.repo.UserExists(ctx, s.repo.db, blockedID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.repo.Create(ctx, tx, blockerID, blockedID, time.Now()); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if err := s.repo.DeleteRelationship(ctx, tx, blockerID, blockedID); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	deleted, err := s.repo.Delete(ctx, s.repo.db, blockerID, blockedID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if !deleted {
+		return ErrBlockNotFound
+	}
+	return nil
+}
+
+func (s *Service) List(ctx context.Context, blockerID int64) (BlockListResponse, error) {
+	blocks, err := s.repo.List(ctx, s.repo.db, blockerID)
+	if err != nil {
+		return BlockListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	return BlockListResponse{Blocks: blocks}, nil
+}
@@ -1,9 +1,13 @@
 package chat
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/vladopadikk/go-chat/internal/apikey"
+	"github.com/vladopadikk/go-chat/internal/validation"
 )
 
 type Handler struct {
@@ -35,7 +39,7 @@ func (h *Handler) CreatePrivateChatHandler(ctx *gin.Context) {
 
 	chat, err := h.service.CreatePrivateChat(ctx.Request.Context(), userID, input)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, err)
 		return
 	}
 
@@ -58,7 +62,7 @@ func (h *Handler) CreateGroupChatHandler(ctx *gin.Context) {
 
 	chat, err := h.service.CreateGroupChat(ctx.Request.Context(), userID, input)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, err)
 		return
 	}
 
@@ -81,11 +85,305 @@ func (h *Handler) GetChatsHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, chatList)
 }
 
+func (h *Handler) GetInvitationsHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.service.ListInvitations(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, invitations)
+}
+
+func (h *Handler) AcceptInvitationHandler(ctx *gin.Context) {
+	userID, invitationID, ok := currentUserAndInvitation(ctx)
+	if !ok {
+		return
+	}
+
+	chat, err := h.service.AcceptInvitation(ctx.Request.Context(), userID, invitationID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, chat)
+}
+
+func (h *Handler) DeclineInvitationHandler(ctx *gin.Context) {
+	userID, invitationID, ok := currentUserAndInvitation(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeclineInvitation(ctx.Request.Context(), userID, invitationID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) GetMembersHandler(ctx *gin.Context) {
+	userID, chatID, ok := currentUserAndChat(ctx)
+	if !ok {
+		return
+	}
+
+	members, err := h.service.ListMembers(ctx.Request.Context(), chatID, userID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, members)
+}
+
+func (h *Handler) RenameChatHandler(ctx *gin.Context) {
+	userID, chatID, ok := currentUserAndChat(ctx)
+	if !ok {
+		return
+	}
+
+	var input RenameChatInput
+	if !bindJSON(ctx, &input) {
+		return
+	}
+
+	if err := h.service.RenameGroup(ctx.Request.Context(), chatID, userID, input); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) GetSettingsHandler(ctx *gin.Context) {
+	userID, chatID, ok := currentUserAndChat(ctx)
+	if !ok {
+		return
+	}
+
+	settings, err := h.service.GetGroupSettings(ctx.Request.Context(), chatID, userID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (h *Handler) UpdateSettingsHandler(ctx *gin.Context) {
+	userID, chatID, ok := currentUserAndChat(ctx)
+	if !ok {
+		return
+	}
+
+	var input UpdateSettingsInput
+	if !bindJSON(ctx, &input) {
+		return
+	}
+
+	settings, err := h.service.UpdateGroupSettings(ctx.Request.Context(), chatID, userID, input)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (h *Handler) SetMemberRoleHandler(ctx *gin.Context) {
+	userID, chatID, ok := currentUserAndChat(ctx)
+	if !ok {
+		return
+	}
+
+	targetID, err := strconv.ParseInt(ctx.Param("userID"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var input SetRoleInput
+	if !bindJSON(ctx, &input) {
+		return
+	}
+
+	if err := h.service.SetMemberRole(ctx.Request.Context(), chatID, userID, targetID, input); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) TransferOwnershipHandler(ctx *gin.Context) {
+	userID, chatID, ok := currentUserAndChat(ctx)
+	if !ok {
+		return
+	}
+
+	var input TransferOwnershipInput
+	if !bindJSON(ctx, &input) {
+		return
+	}
+
+	if err := h.service.TransferOwnership(ctx.Request.Context(), chatID, userID, input); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) AddMembersHandler(ctx *gin.Context) {
+	userID, chatID, ok := currentUserAndChat(ctx)
+	if !ok {
+		return
+	}
+
+	var input AddMembersInput
+	if !bindJSON(ctx, &input) {
+		return
+	}
+
+	resp, err := h.service.AddMembers(ctx.Request.Context(), chatID, userID, input)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) RemoveMemberHandler(ctx *gin.Context) {
+	userID, chatID, ok := currentUserAndChat(ctx)
+	if !ok {
+		return
+	}
+
+	targetID, err := strconv.ParseInt(ctx.Param("userID"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.service.RemoveMember(ctx.Request.Context(), chatID, userID, targetID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) LeaveChatHandler(ctx *gin.Context) {
+	userID, chatID, ok := currentUserAndChat(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Leave(ctx.Request.Context(), chatID, userID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrInvitationNotFound),
+		errors.Is(err, ErrChatNotFound), errors.Is(err, ErrMemberNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidTarget), errors.Is(err, ErrChatWithSelf),
+		errors.Is(err, ErrNotGroupChat), errors.Is(err, ErrInvalidRole), errors.Is(err, ErrInvalidChatName),
+		errors.Is(err, ErrNoMembers):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserBlocked), errors.Is(err, ErrPrivateChatRestricted),
+		errors.Is(err, ErrNotMember), errors.Is(err, ErrPermissionDenied):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func bindJSON(ctx *gin.Context, input any) bool {
+	if err := ctx.ShouldBindJSON(input); err != nil {
+		if fields := validation.FieldErrors(err); fields != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "fields": fields})
+			return false
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return false
+	}
+	return true
+}
+
+func currentUserAndChat(ctx *gin.Context) (int64, int64, bool) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return 0, 0, false
+	}
+
+	chatID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return 0, 0, false
+	}
+	return userID, chatID, true
+}
+
+func currentUserAndInvitation(ctx *gin.Context) (int64, int64, bool) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return 0, 0, false
+	}
+
+	invitationID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid invitation id"})
+		return 0, 0, false
+	}
+	return userID, invitationID, true
+}
+
+func currentUserID(ctx *gin.Context) (int64, bool) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return 0, false
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
+
 func RegisterRoutes(r *gin.RouterGroup, h *Handler) {
 	chats := r.Group("/chats")
 	{
-		chats.POST("/private", h.CreatePrivateChatHandler)
-		chats.POST("/group", h.CreateGroupChatHandler)
-		chats.GET("", h.GetChatsHandler)
+		apikey.Handle(chats, http.MethodPost, "/private", apikey.ScopeChatsWrite, h.CreatePrivateChatHandler)
+		apikey.Handle(chats, http.MethodPost, "/group", apikey.ScopeChatsWrite, h.CreateGroupChatHandler)
+		apikey.Handle(chats, http.MethodGet, "", apikey.ScopeChatsRead, h.GetChatsHandler)
+		apikey.Handle(chats, http.MethodGet, "/invitations", apikey.ScopeChatsRead, h.GetInvitationsHandler)
+		apikey.Handle(chats, http.MethodPost, "/invitations/:id/accept", apikey.ScopeChatsWrite, h.AcceptInvitationHandler)
+		apikey.Handle(chats, http.MethodPost, "/invitations/:id/decline", apikey.ScopeChatsWrite, h.DeclineInvitationHandler)
+		apikey.Handle(chats, http.MethodPatch, "/:id", apikey.ScopeChatsWrite, h.RenameChatHandler)
+		apikey.Handle(chats, http.MethodGet, "/:id/settings", apikey.ScopeChatsRead, h.GetSettingsHandler)
+		apikey.Handle(chats, http.MethodPatch, "/:id/settings", apikey.ScopeChatsWrite, h.UpdateSettingsHandler)
+		apikey.Handle(chats, http.MethodGet, "/:id/members", apikey.ScopeChatsRead, h.GetMembersHandler)
+		apikey.Handle(chats, http.MethodPost, "/:id/members", apikey.ScopeChatsWrite, h.AddMembersHandler)
+		apikey.Handle(chats, http.MethodDelete, "/:id/members/:userID", apikey.ScopeChatsWrite, h.RemoveMemberHandler)
+		apikey.Handle(chats, http.MethodPost, "/:id/leave", apikey.ScopeChatsWrite, h.LeaveChatHandler)
+		apikey.Handle(chats, http.MethodPut, "/:id/members/:userID/role", apikey.ScopeChatsWrite, h.SetMemberRoleHandler)
+		apikey.Handle(chats, http.MethodPut, "/:id/owner", apikey.ScopeChatsWrite, h.TransferOwnershipHandler)
 	}
 }
@@ -0,0 +1,89 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+func (s *Service) ListInvitations(ctx context.Context, userID int64) (InvitationListResponse, error) {
+	invitations, err := s.repo.GetInvitationsForUser(ctx, s.repo.db, userID)
+	if err != nil {
+		return InvitationListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	return InvitationListResponse{Invitations: invitations}, nil
+}
+
+// AcceptInvitation makes the user a member of the group they were invited to.
+// Invitations from someone who has since left the group or lost the right to
+// add members are void and get discarded.
+func (s *Service) AcceptInvitation(ctx context.Context, userID, invitationID int64) (ChatResponse, error) {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	chatID, inviterID, err := s.repo.DeleteInvitation(ctx, tx, invitationID, userID)
+	if err == sql.ErrNoRows {
+		return ChatResponse{}, ErrInvitationNotFound
+	}
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	inviterRole, err := s.repo.GetMemberRole(ctx, tx, chatID, inviterID)
+	if err != nil && err != sql.ErrNoRows {
+		return ChatResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if err == sql.ErrNoRows || !Can(inviterRole, PermissionManageMembers) {
+		if err := tx.Commit(); err != nil {
+			return ChatResponse{}, fmt.Errorf("commit tx: %w", err)
+		}
+		return ChatResponse{}, ErrInvitationNotFound
+	}
+
+	chat, err := s.repo.GetChat(ctx, tx, chatID)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	member, err := s.isMember(ctx, tx, chatID, userID)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	if !member {
+		if err := s.repo.AddMember(ctx, tx, chatID, userID, RoleMember, time.Now()); err != nil {
+			return ChatResponse{}, fmt.Errorf("db error: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ChatResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	if !member {
+		s.subscriber.Subscribe(userID, chatID)
+	}
+
+	return ChatResponse{
+		ID:        chat.ID,
+		Type:      chat.Type,
+		CreatedAt: chat.CreatedAt,
+	}, nil
+}
+
+func (s *Service) DeclineInvitation(ctx context.Context, userID, invitationID int64) error {
+	_, _, err := s.repo.DeleteInvitation(ctx, s.repo.db, invitationID, userID)
+	if err == sql.ErrNoRows {
+		return ErrInvitationNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+)
+
+var ErrNoMembers = errors.New("at least one of user_ids and usernames is required")
+
+// AddMembers adds users to a group. Users whose privacy settings do not let
+// the caller add them directly are invited instead, users who have blocked
+// the caller are silently skipped and existing members are left as they are.
+func (s *Service) AddMembers(ctx context.Context, chatID, userID int64, input AddMembersInput) (AddMembersResponse, error) {
+	if len(input.UserIDs) == 0 && len(input.Usernames) == 0 {
+		return AddMembersResponse{}, ErrNoMembers
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return AddMembersResponse{}, fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := s.requirePermission(ctx, tx, chatID, userID, PermissionManageMembers); err != nil {
+		return AddMembersResponse{}, err
+	}
+
+	ids, err := s.resolveUsers(ctx, tx, input.UserIDs, input.Usernames)
+	if err != nil {
+		return AddMembersResponse{}, err
+	}
+
+	members, invited, err := s.filterInvitees(ctx, tx, userID, ids)
+	if err != nil {
+		return AddMembersResponse{}, err
+	}
+
+	resp := AddMembersResponse{Added: []int64{}, Invited: []int64{}}
+	now := time.Now()
+
+	for _, id := range members {
+		isMember, err := s.isMember(ctx, tx, chatID, id)
+		if err != nil {
+			return AddMembersResponse{}, err
+		}
+		if isMember {
+			continue
+		}
+		if err := s.repo.AddMember(ctx, tx, chatID, id, RoleMember, now); err != nil {
+			return AddMembersResponse{}, fmt.Errorf("db error: %w", err)
+		}
+		resp.Added = append(resp.Added, id)
+	}
+
+	for _, id := range invited {
+		isMember, err := s.isMember(ctx, tx, chatID, id)
+		if err != nil {
+			return AddMembersResponse{}, err
+		}
+		if isMember {
+			continue
+		}
+		if err := s.repo.CreateInvitation(ctx, tx, chatID, userID, id, now); err != nil {
+			return AddMembersResponse{}, fmt.Errorf("db error: %w", err)
+		}
+		resp.Invited = append(resp.Invited, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return AddMembersResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	for _, id := range resp.Added {
+		s.subscriber.Subscribe(id, chatID)
+	}
+
+	return resp, nil
+}
+
+// RemoveMember removes someone ranked below the caller from a group.
+// Removing yourself is the same as leaving.
+func (s *Service) RemoveMember(ctx context.Context, chatID, userID, targetID int64) error {
+	if targetID == userID {
+		return s.Leave(ctx, chatID, userID)
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	role, err := s.requirePermission(ctx, tx, chatID, userID, PermissionManageMembers)
+	if err != nil {
+		return err
+	}
+
+	targetRole, err := s.targetRole(ctx, tx, chatID, targetID)
+	if err != nil {
+		return err
+	}
+	if !outranks(role, targetRole) {
+		return ErrPermissionDenied
+	}
+
+	if err := s.repo.RemoveMember(ctx, tx, chatID, targetID); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	s.subscriber.Unsubscribe(targetID, chatID)
+	return nil
+}
+
+// Leave removes the caller from a group. An owner who leaves hands the group
+// to its longest-standing admin, or member if there is no admin; the last
+// member to leave deletes it.
+func (s *Service) Leave(ctx context.Context, chatID, userID int64) error {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	role, err := s.groupRole(ctx, tx, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.RemoveMember(ctx, tx, chatID, userID); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	if role == RoleOwner {
+		promoted, err := s.repo.PromoteSuccessor(ctx, tx, chatID)
+		if err != nil {
+			return fmt.Errorf("db error: %w", err)
+		}
+		if !promoted {
+			if err := s.repo.DeleteChat(ctx, tx, chatID); err != nil {
+				return fmt.Errorf("db error: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	s.subscriber.Unsubscribe(userID, chatID)
+	return nil
+}
+
+func (s *Service) isMember(ctx context.Context, exec database.Executor, chatID, userID int64) (bool, error) {
+	_, err := s.repo.GetMemberRole(ctx, exec, chatID, userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("db error: %w", err)
+	}
+	return true, nil
+}
@@ -11,22 +11,96 @@ type Chat struct {
 type ChatMember struct {
 	ChatID   int64
 	UserID   int64
+	Role     string
 	JoinedAt time.Time
 }
 
+// CreatePrivateChatInput names the other user either by id or by username.
 type CreatePrivateChatInput struct {
-	UserID int64 `json:"user_id"`
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
 }
 
+// CreateGroupChatInput lists members by id, by username or both.
 type CreateGroupChatInput struct {
-	Name         string  `json:"name"`
-	Participants []int64 `json:"participants"`
+	Name         string   `json:"name"`
+	Participants []int64  `json:"participants"`
+	Usernames    []string `json:"usernames"`
 }
 
+// Privacy holds the settings of a user that decide whether others may start
+// a private chat with them or add them to a group without asking.
+type Privacy struct {
+	PrivateChats string
+	GroupInvites string
+}
+
+// ChatResponse describes a chat. Invited lists, on creation, the users who
+// were sent an invitation instead of being added.
 type ChatResponse struct {
 	ID        int64     `json:"id"`
 	Type      string    `json:"type"`
 	CreatedAt time.Time `json:"created_at"`
+	Invited   []int64   `json:"invited,omitempty"`
+}
+
+// AddMembersInput lists the users to add by id, by username or both.
+type AddMembersInput struct {
+	UserIDs   []int64  `json:"user_ids"`
+	Usernames []string `json:"usernames"`
+}
+
+// AddMembersResponse tells apart the users that joined right away from those
+// who were sent an invitation.
+type AddMembersResponse struct {
+	Added   []int64 `json:"added"`
+	Invited []int64 `json:"invited"`
+}
+
+type RenameChatInput struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+// GroupSettings are the group-wide settings every member can read.
+type GroupSettings struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type UpdateSettingsInput struct {
+	Description string `json:"description" binding:"max=500"`
+}
+
+type SetRoleInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type TransferOwnershipInput struct {
+	UserID int64 `json:"user_id" binding:"required"`
+}
+
+type MemberResponse struct {
+	UserID      int64     `json:"user_id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Role        string    `json:"role"`
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+type MemberListResponse struct {
+	Members []MemberResponse `json:"members"`
+}
+
+type InvitationResponse struct {
+	ID        int64     `json:"id"`
+	ChatID    int64     `json:"chat_id"`
+	ChatName  string    `json:"chat_name"`
+	InviterID int64     `json:"inviter_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type InvitationListResponse struct {
+	Invitations []InvitationResponse `json:"invitations"`
 }
 
 type ChatListResponse struct {
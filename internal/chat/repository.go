@@ -27,12 +27,12 @@ func (r *Repository) CreateChat(ctx context.Context, exec database.Executor, typ
 	return chat, err
 }
 
-func (r *Repository) AddMember(ctx context.Context, exec database.Executor, chatID, userID int64, joinedAt time.Time) error {
+func (r *Repository) AddMember(ctx context.Context, exec database.Executor, chatID, userID int64, role string, joinedAt time.Time) error {
 	query := `
-		INSERT INTO chat_members (chat_id, user_id, joined_at)
-		VALUES ($1, $2, $3)
+		INSERT INTO chat_members (chat_id, user_id, role, joined_at)
+		VALUES ($1, $2, $3, $4)
 	`
-	_, err := exec.ExecContext(ctx, query, chatID, userID, joinedAt)
+	_, err := exec.ExecContext(ctx, query, chatID, userID, role, joinedAt)
 	return err
 }
 
@@ -111,3 +111,275 @@ func (r *Repository) ChatExists(ctx context.Context, chatID int64) (bool, error)
 	}
 	return true, nil
 }
+
+// GetUserIDsByUsernames maps lower-cased usernames of existing accounts to
+// their ids.
+func (r *Repository) GetUserIDsByUsernames(ctx context.Context, exec database.Executor, usernames []string) (map[string]int64, error) {
+	query := `
+		SELECT id, LOWER(username)
+		FROM users
+		WHERE LOWER(username) = ANY($1) AND deleted_at IS NULL;
+	`
+	rows, err := exec.QueryContext(ctx, query, usernames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int64, len(usernames))
+	for rows.Next() {
+		var id int64
+		var username string
+		if err := rows.Scan(&id, &username); err != nil {
+			return nil, err
+		}
+		ids[username] = id
+	}
+
+	return ids, rows.Err()
+}
+
+// GetExistingUserIDs returns which of the ids belong to accounts that have
+// not been deleted.
+func (r *Repository) GetExistingUserIDs(ctx context.Context, exec database.Executor, userIDs []int64) (map[int64]bool, error) {
+	query := `
+		SELECT id
+		FROM users
+		WHERE id = ANY($1) AND deleted_at IS NULL;
+	`
+	rows, err := exec.QueryContext(ctx, query, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[int64]bool, len(userIDs))
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		existing[id] = true
+	}
+
+	return existing, rows.Err()
+}
+
+// GetPrivatePeer returns the other member of a private chat. It reports false
+// for group chats.
+func (r *Repository) GetPrivatePeer(ctx context.Context, exec database.Executor, chatID, userID int64) (int64, bool, error) {
+	query := `
+		SELECT cm.user_id
+		FROM chats c
+		JOIN chat_members cm ON cm.chat_id = c.id
+		WHERE c.id = $1 AND c.type = 'private' AND cm.user_id <> $2;
+	`
+	var peerID int64
+	err := exec.QueryRowContext(ctx, query, chatID, userID).Scan(&peerID)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return peerID, true, nil
+}
+
+// GetPrivacy returns who the user accepts new private chats and group
+// additions from.
+func (r *Repository) GetPrivacy(ctx context.Context, exec database.Executor, userID int64) (Privacy, error) {
+	query := `
+		SELECT privacy_private_chats, privacy_group_invites
+		FROM users
+		WHERE id = $1;
+	`
+	var privacy Privacy
+	err := exec.QueryRowContext(ctx, query, userID).Scan(&privacy.PrivateChats, &privacy.GroupInvites)
+	return privacy, err
+}
+
+// CreateInvitation records a pending invitation. Inviting someone who already
+// has one for the chat is not an error.
+func (r *Repository) CreateInvitation(ctx context.Context, exec database.Executor, chatID, inviterID, inviteeID int64, createdAt time.Time) error {
+	query := `
+		INSERT INTO chat_invitations (chat_id, inviter_id, invitee_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (chat_id, invitee_id) DO NOTHING;
+	`
+	_, err := exec.ExecContext(ctx, query, chatID, inviterID, inviteeID, createdAt)
+	return err
+}
+
+func (r *Repository) GetInvitationsForUser(ctx context.Context, exec database.Executor, userID int64) ([]InvitationResponse, error) {
+	query := `
+		SELECT i.id, i.chat_id, COALESCE(c.name, ''), i.inviter_id, i.created_at
+		FROM chat_invitations i
+		JOIN chats c ON c.id = i.chat_id
+		WHERE i.invitee_id = $1
+		ORDER BY i.created_at DESC;
+	`
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []InvitationResponse{}
+	for rows.Next() {
+		var inv InvitationResponse
+		if err := rows.Scan(&inv.ID, &inv.ChatID, &inv.ChatName, &inv.InviterID, &inv.CreatedAt); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+
+	return invitations, rows.Err()
+}
+
+// DeleteInvitation removes an invitation addressed to the user and returns
+// the chat it was for and who sent it.
+func (r *Repository) DeleteInvitation(ctx context.Context, exec database.Executor, id, inviteeID int64) (int64, int64, error) {
+	query := `
+		DELETE FROM chat_invitations
+		WHERE id = $1 AND invitee_id = $2
+		RETURNING chat_id, inviter_id;
+	`
+	var chatID, inviterID int64
+	err := exec.QueryRowContext(ctx, query, id, inviteeID).Scan(&chatID, &inviterID)
+	return chatID, inviterID, err
+}
+
+func (r *Repository) GetChat(ctx context.Context, exec database.Executor, chatID int64) (Chat, error) {
+	query := `
+		SELECT id, type, created_at
+		FROM chats
+		WHERE id = $1;
+	`
+	var chat Chat
+	err := exec.QueryRowContext(ctx, query, chatID).Scan(&chat.ID, &chat.Type, &chat.CreatedAt)
+	return chat, err
+}
+
+func (r *Repository) GetMemberRole(ctx context.Context, exec database.Executor, chatID, userID int64) (string, error) {
+	query := `
+		SELECT role
+		FROM chat_members
+		WHERE chat_id = $1 AND user_id = $2;
+	`
+	var role string
+	err := exec.QueryRowContext(ctx, query, chatID, userID).Scan(&role)
+	return role, err
+}
+
+func (r *Repository) SetMemberRole(ctx context.Context, exec database.Executor, chatID, userID int64, role string) error {
+	query := `
+		UPDATE chat_members
+		SET role = $3
+		WHERE chat_id = $1 AND user_id = $2;
+	`
+	_, err := exec.ExecContext(ctx, query, chatID, userID, role)
+	return err
+}
+
+func (r *Repository) GetMembers(ctx context.Context, exec database.Executor, chatID int64) ([]MemberResponse, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, cm.role, cm.joined_at
+		FROM chat_members cm
+		JOIN users u ON u.id = cm.user_id
+		WHERE cm.chat_id = $1
+		ORDER BY cm.joined_at, u.id;
+	`
+	rows, err := exec.QueryContext(ctx, query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []MemberResponse{}
+	for rows.Next() {
+		var m MemberResponse
+		if err := rows.Scan(&m.UserID, &m.Username, &m.DisplayName, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *Repository) RenameChat(ctx context.Context, exec database.Executor, chatID int64, name string) error {
+	query := `
+		UPDATE chats
+		SET name = $2
+		WHERE id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, chatID, name)
+	return err
+}
+
+func (r *Repository) GetSettings(ctx context.Context, exec database.Executor, chatID int64) (GroupSettings, error) {
+	query := `
+		SELECT COALESCE(name, ''), description
+		FROM chats
+		WHERE id = $1;
+	`
+	var settings GroupSettings
+	err := exec.QueryRowContext(ctx, query, chatID).Scan(&settings.Name, &settings.Description)
+	return settings, err
+}
+
+func (r *Repository) UpdateSettings(ctx context.Context, exec database.Executor, chatID int64, description string) error {
+	query := `
+		UPDATE chats
+		SET description = $2
+		WHERE id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, chatID, description)
+	return err
+}
+
+func (r *Repository) RemoveMember(ctx context.Context, exec database.Executor, chatID, userID int64) error {
+	query := `
+		DELETE FROM chat_members
+		WHERE chat_id = $1 AND user_id = $2;
+	`
+	_, err := exec.ExecContext(ctx, query, chatID, userID)
+	return err
+}
+
+// PromoteSuccessor makes the longest-standing admin of the chat its owner, or
+// the longest-standing member if there is no admin. It reports false when the
+// chat has no members left.
+func (r *Repository) PromoteSuccessor(ctx context.Context, exec database.Executor, chatID int64) (bool, error) {
+	query := `
+		UPDATE chat_members cm
+		SET role = 'owner'
+		FROM (
+			SELECT user_id
+			FROM chat_members
+			WHERE chat_id = $1
+			ORDER BY role = 'admin' DESC, joined_at, user_id
+			LIMIT 1
+		) next
+		WHERE cm.chat_id = $1 AND cm.user_id = next.user_id;
+	`
+	res, err := exec.ExecContext(ctx, query, chatID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *Repository) DeleteChat(ctx context.Context, exec database.Executor, chatID int64) error {
+	query := `
+		DELETE FROM chats
+		WHERE id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, chatID)
+	return err
+}
@@ -0,0 +1,249 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Permission is something a group member may or may not do depending on
+// their role.
+type Permission int
+
+const (
+	PermissionRename Permission = iota
+	PermissionManageMembers
+	PermissionDeleteMessages
+	PermissionManageRoles
+	PermissionChangeSettings
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleOwner:  {PermissionRename, PermissionManageMembers, PermissionDeleteMessages, PermissionManageRoles, PermissionChangeSettings},
+	RoleAdmin:  {PermissionRename, PermissionManageMembers, PermissionDeleteMessages, PermissionChangeSettings},
+	RoleMember: {},
+}
+
+var roleRanks = map[string]int{
+	RoleMember: 0,
+	RoleAdmin:  1,
+	RoleOwner:  2,
+}
+
+var ErrChatNotFound = errors.New("chat not found")
+var ErrNotMember = errors.New("you are not a member of this chat")
+var ErrMemberNotFound = errors.New("user is not a member of this chat")
+var ErrNotGroupChat = errors.New("only group chats have roles")
+var ErrPermissionDenied = errors.New("your role in this chat does not allow this")
+var ErrInvalidRole = errors.New("role must be admin or member")
+var ErrInvalidChatName = errors.New("name cannot be empty")
+
+// Can reports whether the role grants the permission.
+func Can(role string, perm Permission) bool {
+	return slices.Contains(rolePermissions[role], perm)
+}
+
+// outranks reports whether a member with role a may act on one with role b.
+func outranks(a, b string) bool {
+	return roleRanks[a] > roleRanks[b]
+}
+
+// CheckPermission fails unless the user is a member of the group chat whose
+// role grants the permission.
+func (s *Service) CheckPermission(ctx context.Context, chatID, userID int64, perm Permission) error {
+	_, err := s.requirePermission(ctx, s.repo.db, chatID, userID, perm)
+	return err
+}
+
+func (s *Service) ListMembers(ctx context.Context, chatID, userID int64) (MemberListResponse, error) {
+	if _, err := s.memberRole(ctx, s.repo.db, chatID, userID); err != nil {
+		return MemberListResponse{}, err
+	}
+
+	members, err := s.repo.GetMembers(ctx, s.repo.db, chatID)
+	if err != nil {
+		return MemberListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	return MemberListResponse{Members: members}, nil
+}
+
+func (s *Service) RenameGroup(ctx context.Context, chatID, userID int64, input RenameChatInput) error {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return ErrInvalidChatName
+	}
+
+	if _, err := s.requirePermission(ctx, s.repo.db, chatID, userID, PermissionRename); err != nil {
+		return err
+	}
+
+	if err := s.repo.RenameChat(ctx, s.repo.db, chatID, name); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) GetGroupSettings(ctx context.Context, chatID, userID int64) (GroupSettings, error) {
+	if _, err := s.groupRole(ctx, s.repo.db, chatID, userID); err != nil {
+		return GroupSettings{}, err
+	}
+
+	settings, err := s.repo.GetSettings(ctx, s.repo.db, chatID)
+	if err != nil {
+		return GroupSettings{}, fmt.Errorf("db error: %w", err)
+	}
+	return settings, nil
+}
+
+func (s *Service) UpdateGroupSettings(ctx context.Context, chatID, userID int64, input UpdateSettingsInput) (GroupSettings, error) {
+	if _, err := s.requirePermission(ctx, s.repo.db, chatID, userID, PermissionChangeSettings); err != nil {
+		return GroupSettings{}, err
+	}
+
+	if err := s.repo.UpdateSettings(ctx, s.repo.db, chatID, strings.TrimSpace(input.Description)); err != nil {
+		return GroupSettings{}, fmt.Errorf("db error: %w", err)
+	}
+	return s.GetGroupSettings(ctx, chatID, userID)
+}
+
+// SetMemberRole promotes a member to admin or demotes an admin. The owner's
+// role only changes through TransferOwnership.
+func (s *Service) SetMemberRole(ctx context.Context, chatID, userID, targetID int64, input SetRoleInput) error {
+	if input.Role != RoleAdmin && input.Role != RoleMember {
+		return ErrInvalidRole
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	role, err := s.requirePermission(ctx, tx, chatID, userID, PermissionManageRoles)
+	if err != nil {
+		return err
+	}
+
+	targetRole, err := s.targetRole(ctx, tx, chatID, targetID)
+	if err != nil {
+		return err
+	}
+	if !outranks(role, targetRole) {
+		return ErrPermissionDenied
+	}
+
+	if err := s.repo.SetMemberRole(ctx, tx, chatID, targetID, input.Role); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// TransferOwnership hands the group over to another member. The previous
+// owner stays on as an admin.
+func (s *Service) TransferOwnership(ctx context.Context, chatID, userID int64, input TransferOwnershipInput) error {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	role, err := s.groupRole(ctx, tx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if role != RoleOwner {
+		return ErrPermissionDenied
+	}
+	if input.UserID == userID {
+		return nil
+	}
+
+	if _, err := s.targetRole(ctx, tx, chatID, input.UserID); err != nil {
+		return err
+	}
+
+	// The previous owner is demoted first: a chat has at most one owner.
+	if err := s.repo.SetMemberRole(ctx, tx, chatID, userID, RoleAdmin); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if err := s.repo.SetMemberRole(ctx, tx, chatID, input.UserID, RoleOwner); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// requirePermission returns the user's role in the group chat if it grants
+// the permission.
+func (s *Service) requirePermission(ctx context.Context, exec database.Executor, chatID, userID int64, perm Permission) (string, error) {
+	role, err := s.groupRole(ctx, exec, chatID, userID)
+	if err != nil {
+		return "", err
+	}
+	if !Can(role, perm) {
+		return "", ErrPermissionDenied
+	}
+	return role, nil
+}
+
+// groupRole is memberRole for chats that have to be groups.
+func (s *Service) groupRole(ctx context.Context, exec database.Executor, chatID, userID int64) (string, error) {
+	chat, err := s.repo.GetChat(ctx, exec, chatID)
+	if err == sql.ErrNoRows {
+		return "", ErrChatNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("db error: %w", err)
+	}
+
+	role, err := s.memberRole(ctx, exec, chatID, userID)
+	if err != nil {
+		return "", err
+	}
+	if chat.Type != "group" {
+		return "", ErrNotGroupChat
+	}
+	return role, nil
+}
+
+// memberRole returns the caller's role, failing if they are not a member.
+func (s *Service) memberRole(ctx context.Context, exec database.Executor, chatID, userID int64) (string, error) {
+	role, err := s.repo.GetMemberRole(ctx, exec, chatID, userID)
+	if err == sql.ErrNoRows {
+		return "", ErrNotMember
+	}
+	if err != nil {
+		return "", fmt.Errorf("db error: %w", err)
+	}
+	return role, nil
+}
+
+// targetRole returns the role of a member the caller wants to act on.
+func (s *Service) targetRole(ctx context.Context, exec database.Executor, chatID, targetID int64) (string, error) {
+	role, err := s.repo.GetMemberRole(ctx, exec, chatID, targetID)
+	if err == sql.ErrNoRows {
+		return "", ErrMemberNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("db error: %w", err)
+	}
+	return role, nil
+}
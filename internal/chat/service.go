@@ -3,16 +3,38 @@ package chat
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/vladopadikk/go-chat/internal/block"
+	"github.com/vladopadikk/go-chat/internal/contact"
+	"github.com/vladopadikk/go-chat/internal/database"
 )
 
+var ErrUserNotFound = errors.New("user not found")
+var ErrInvalidTarget = errors.New("exactly one of user_id and username is required")
+var ErrChatWithSelf = errors.New("cannot start a private chat with yourself")
+var ErrUserBlocked = errors.New("you have blocked this user")
+var ErrPrivateChatRestricted = errors.New("this user does not accept private chats from you")
+
+// Subscriber keeps already open WebSocket connections in step with chat
+// membership.
+type Subscriber interface {
+	Subscribe(userID, chatID int64)
+	Unsubscribe(userID, chatID int64)
+}
+
 type Service struct {
-	repo *Repository
+	repo        *Repository
+	blockRepo   *block.Repository
+	contactRepo *contact.Repository
+	subscriber  Subscriber
 }
 
-func NewService(repo *Repository) *Service {
-	return &Service{repo}
+func NewService(repo *Repository, blockRepo *block.Repository, contactRepo *contact.Repository, subscriber Subscriber) *Service {
+	return &Service{repo, blockRepo, contactRepo, subscriber}
 }
 
 func (s *Service) CreatePrivateChat(ctx context.Context, userID int64, createPrivateChatIn CreatePrivateChatInput) (ChatResponse, error) {
@@ -22,23 +44,67 @@ func (s *Service) CreatePrivateChat(ctx context.Context, userID int64, createPri
 	}
 	defer tx.Rollback()
 
-	chat, err := s.repo.FindPrivateChatBetweenUsers(ctx, s.repo.db, userID, createPrivateChatIn.UserID)
+	if (createPrivateChatIn.UserID != 0) == (createPrivateChatIn.Username != "") {
+		return ChatResponse{}, ErrInvalidTarget
+	}
+
+	var ids []int64
+	var usernames []string
+	if createPrivateChatIn.UserID != 0 {
+		ids = append(ids, createPrivateChatIn.UserID)
+	} else {
+		usernames = append(usernames, createPrivateChatIn.Username)
+	}
+
+	targets, err := s.resolveUsers(ctx, tx, ids, usernames)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	targetID := targets[0]
+	if targetID == userID {
+		return ChatResponse{}, ErrChatWithSelf
+	}
+
+	blocked, err := s.blockRepo.IsBlocked(ctx, tx, userID, targetID)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if blocked {
+		return ChatResponse{}, ErrUserBlocked
+	}
+
+	chat, err := s.repo.FindPrivateChatBetweenUsers(ctx, s.repo.db, userID, targetID)
 	if err != nil && err != sql.ErrNoRows {
 		return ChatResponse{}, fmt.Errorf("db error: %w", err)
 	}
 
-	if err == sql.ErrNoRows {
+	created := err == sql.ErrNoRows
+	if created {
+		// Someone who blocked the caller looks exactly like an account that
+		// does not exist.
+		blockedBy, err := s.blockRepo.IsBlocked(ctx, tx, targetID, userID)
+		if err != nil {
+			return ChatResponse{}, fmt.Errorf("db error: %w", err)
+		}
+		if blockedBy {
+			return ChatResponse{}, notFound(createPrivateChatIn.UserID, createPrivateChatIn.Username)
+		}
+
+		if err := s.checkPrivateChatPrivacy(ctx, tx, userID, targetID); err != nil {
+			return ChatResponse{}, err
+		}
+
 		chat, err = s.repo.CreateChat(ctx, tx, "private", "")
 		if err != nil {
 			return ChatResponse{}, fmt.Errorf("db error: %w", err)
 		}
 		joinedAt := time.Now()
 
-		err = s.repo.AddMember(ctx, tx, chat.ID, userID, joinedAt)
+		err = s.repo.AddMember(ctx, tx, chat.ID, userID, RoleMember, joinedAt)
 		if err != nil {
 			return ChatResponse{}, fmt.Errorf("db error: %w", err)
 		}
-		err = s.repo.AddMember(ctx, tx, chat.ID, createPrivateChatIn.UserID, joinedAt)
+		err = s.repo.AddMember(ctx, tx, chat.ID, targetID, RoleMember, joinedAt)
 		if err != nil {
 			return ChatResponse{}, fmt.Errorf("db error: %w", err)
 		}
@@ -48,6 +114,11 @@ func (s *Service) CreatePrivateChat(ctx context.Context, userID int64, createPri
 		return ChatResponse{}, fmt.Errorf("commit tx: %w", err)
 	}
 
+	if created {
+		s.subscriber.Subscribe(userID, chat.ID)
+		s.subscriber.Subscribe(targetID, chat.ID)
+	}
+
 	return ChatResponse{
 		ID:        chat.ID,
 		Type:      chat.Type,
@@ -62,6 +133,18 @@ func (s *Service) CreateGroupChat(ctx context.Context, userID int64, createGroup
 	}
 	defer tx.Rollback()
 
+	// The creator is always a member, whether or not they listed themselves.
+	ids := append([]int64{userID}, createGroupChatIn.Participants...)
+	participants, err := s.resolveUsers(ctx, tx, ids, createGroupChatIn.Usernames)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+
+	participants, invited, err := s.filterInvitees(ctx, tx, userID, participants)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+
 	chat, err := s.repo.CreateChat(ctx, tx, "group", createGroupChatIn.Name)
 	if err != nil {
 		return ChatResponse{}, fmt.Errorf("db error: %w", err)
@@ -69,8 +152,19 @@ func (s *Service) CreateGroupChat(ctx context.Context, userID int64, createGroup
 
 	joinedAt := time.Now()
 
-	for _, participant := range createGroupChatIn.Participants {
-		err = s.repo.AddMember(ctx, tx, chat.ID, participant, joinedAt)
+	for _, participant := range participants {
+		role := RoleMember
+		if participant == userID {
+			role = RoleOwner
+		}
+		err = s.repo.AddMember(ctx, tx, chat.ID, participant, role, joinedAt)
+		if err != nil {
+			return ChatResponse{}, fmt.Errorf("db error: %w", err)
+		}
+	}
+
+	for _, invitee := range invited {
+		err = s.repo.CreateInvitation(ctx, tx, chat.ID, userID, invitee, joinedAt)
 		if err != nil {
 			return ChatResponse{}, fmt.Errorf("db error: %w", err)
 		}
@@ -80,10 +174,15 @@ func (s *Service) CreateGroupChat(ctx context.Context, userID int64, createGroup
 		return ChatResponse{}, fmt.Errorf("commit tx: %w", err)
 	}
 
+	for _, participant := range participants {
+		s.subscriber.Subscribe(participant, chat.ID)
+	}
+
 	return ChatResponse{
 		ID:        chat.ID,
 		Type:      chat.Type,
 		CreatedAt: chat.CreatedAt,
+		Invited:   invited,
 	}, nil
 }
 
@@ -97,3 +196,139 @@ func (s *Service) GetChatsList(ctx context.Context, userID int64) (ChatListRespo
 		Chats: chatList,
 	}, nil
 }
+
+// resolveUsers turns user ids and usernames into a list of distinct ids,
+// failing on the first one that does not name an existing account.
+func (s *Service) resolveUsers(ctx context.Context, exec database.Executor, ids []int64, usernames []string) ([]int64, error) {
+	resolved := make([]int64, 0, len(ids)+len(usernames))
+	seen := make(map[int64]bool, len(ids)+len(usernames))
+
+	add := func(id int64) {
+		if !seen[id] {
+			seen[id] = true
+			resolved = append(resolved, id)
+		}
+	}
+
+	if len(ids) > 0 {
+		existing, err := s.repo.GetExistingUserIDs(ctx, exec, ids)
+		if err != nil {
+			return nil, fmt.Errorf("db error: %w", err)
+		}
+		for _, id := range ids {
+			if !existing[id] {
+				return nil, notFound(id, "")
+			}
+			add(id)
+		}
+	}
+
+	if len(usernames) > 0 {
+		handles := make([]string, len(usernames))
+		for i, username := range usernames {
+			handles[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+		}
+
+		byUsername, err := s.repo.GetUserIDsByUsernames(ctx, exec, handles)
+		if err != nil {
+			return nil, fmt.Errorf("db error: %w", err)
+		}
+		for i, username := range usernames {
+			id, ok := byUsername[handles[i]]
+			if !ok {
+				return nil, notFound(0, username)
+			}
+			add(id)
+		}
+	}
+
+	return resolved, nil
+}
+
+// filterInvitees drops the users who have blocked the inviter, without
+// telling the inviter, and refuses users the inviter has blocked. Of the
+// rest, those whose privacy settings let the inviter add them directly are
+// returned as members and the others as users to invite.
+func (s *Service) filterInvitees(ctx context.Context, exec database.Executor, inviterID int64, userIDs []int64) ([]int64, []int64, error) {
+	members := make([]int64, 0, len(userIDs))
+	var invited []int64
+	for _, id := range userIDs {
+		if id == inviterID {
+			members = append(members, id)
+			continue
+		}
+
+		blocked, err := s.blockRepo.IsBlocked(ctx, exec, inviterID, id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("db error: %w", err)
+		}
+		if blocked {
+			return nil, nil, fmt.Errorf("%w: %d", ErrUserBlocked, id)
+		}
+
+		blockedBy, err := s.blockRepo.IsBlocked(ctx, exec, id, inviterID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("db error: %w", err)
+		}
+		if blockedBy {
+			continue
+		}
+
+		privacy, err := s.repo.GetPrivacy(ctx, exec, id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("db error: %w", err)
+		}
+
+		allowed, err := s.allows(ctx, exec, privacy.GroupInvites, inviterID, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if allowed {
+			members = append(members, id)
+		} else {
+			invited = append(invited, id)
+		}
+	}
+	return members, invited, nil
+}
+
+func notFound(userID int64, username string) error {
+	if username != "" {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	return fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+}
+
+// checkPrivateChatPrivacy enforces the target's choice of who may start a
+// private chat with them. Existing chats are not affected by it.
+func (s *Service) checkPrivateChatPrivacy(ctx context.Context, exec database.Executor, userID, targetID int64) error {
+	privacy, err := s.repo.GetPrivacy(ctx, exec, targetID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	allowed, err := s.allows(ctx, exec, privacy.PrivateChats, userID, targetID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrPrivateChatRestricted
+	}
+	return nil
+}
+
+// allows reports whether a privacy level of the target covers the user.
+func (s *Service) allows(ctx context.Context, exec database.Executor, level string, userID, targetID int64) (bool, error) {
+	switch level {
+	case "everyone":
+		return true, nil
+	case "contacts":
+		contacts, err := s.contactRepo.AreContacts(ctx, exec, userID, targetID)
+		if err != nil {
+			return false, fmt.Errorf("db error: %w", err)
+		}
+		return contacts, nil
+	default:
+		return false, nil
+	}
+}
@@ -1,13 +1,21 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	AppPort string
+	AppPort    string
+	AppBaseURL string
+	// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For is
+	// believed. With none, the client IP is always the connection's peer.
+	TrustedProxies []string
 
 	DBHost     string
 	DBPort     string
@@ -15,14 +23,71 @@ type Config struct {
 	DBPassword string
 	DBName     string
 
-	JWTSecret string
+	JWTSigningAlg   string
+	JWTKeyRotation  time.Duration
+	JWTIssuer       string
+	JWTAudience     string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	MFAChallengeTTL time.Duration
+	WSTicketTTL     time.Duration
+	TOTPIssuer      string
+	ReauthMaxAge    time.Duration
+
+	LoginMaxFailures   int
+	LoginIPMaxFailures int
+	LoginFailureWindow time.Duration
+	LoginLockoutBase   time.Duration
+	LoginLockoutMax    time.Duration
+
+	PasswordResetTTL      time.Duration
+	PasswordResetCooldown time.Duration
+
+	PasswordMinLength    int
+	PasswordMaxLength    int
+	PasswordMinClasses   int
+	PasswordBreachedList string
+
+	PasswordHashAlgorithm string
+	Argon2Memory          int
+	Argon2Iterations      int
+	Argon2Parallelism     int
+	BcryptCost            int
+
+	PasswordHashConcurrency int
+
+	EmailVerificationTTL      time.Duration
+	EmailVerificationCooldown time.Duration
+
+	UsernameChangeCooldown time.Duration
+
+	AccountDeletionGrace    time.Duration
+	AccountDeletionMessages string
+
+	OIDCIssuerURL     string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCScopes        string
+	OIDCAutoProvision bool
+
+	MailDriver   string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
 }
 
 func Load() *Config {
 	_ = godotenv.Load("../../.env")
 
 	cfg := &Config{
-		AppPort: getEnv("APP_PORT", "8080"),
+		AppPort:    getEnv("APP_PORT", "8080"),
+		AppBaseURL: getEnv("APP_BASE_URL", "http://localhost:8080"),
+
+		TrustedProxies: getEnvList("TRUSTED_PROXIES"),
 
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
@@ -30,7 +95,65 @@ func Load() *Config {
 		DBPassword: getEnv("DB_PASSWORD", ""),
 		DBName:     getEnv("DB_NAME", "postgres"),
 
-		JWTSecret: getEnv("JWT_SECRET", "dev_secret"),
+		JWTSigningAlg:   getEnv("JWT_SIGNING_ALG", "EdDSA"),
+		JWTKeyRotation:  getEnvDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+		JWTIssuer:       getEnv("JWT_ISSUER", "go-chat"),
+		JWTAudience:     getEnv("JWT_AUDIENCE", "go-chat"),
+		AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		MFAChallengeTTL: getEnvDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+		WSTicketTTL:     getEnvDuration("WS_TICKET_TTL", 30*time.Second),
+		TOTPIssuer:      getEnv("TOTP_ISSUER", "go-chat"),
+		ReauthMaxAge:    getEnvDuration("REAUTH_MAX_AGE", 10*time.Minute),
+
+		LoginMaxFailures:   getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures: getEnvInt("LOGIN_IP_MAX_FAILURES", 20),
+		LoginFailureWindow: getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginLockoutBase:   getEnvDuration("LOGIN_LOCKOUT_BASE", time.Minute),
+		LoginLockoutMax:    getEnvDuration("LOGIN_LOCKOUT_MAX", time.Hour),
+
+		PasswordResetTTL:      getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+		PasswordResetCooldown: getEnvDuration("PASSWORD_RESET_COOLDOWN", time.Minute),
+
+		PasswordMinLength:    getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxLength:    getEnvInt("PASSWORD_MAX_LENGTH", 128),
+		PasswordMinClasses:   getEnvInt("PASSWORD_MIN_CLASSES", 1),
+		PasswordBreachedList: getEnv("PASSWORD_BREACHED_LIST", ""),
+
+		PasswordHashAlgorithm: getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		Argon2Memory:          getEnvInt("ARGON2_MEMORY", 64*1024),
+		Argon2Iterations:      getEnvInt("ARGON2_ITERATIONS", 3),
+		Argon2Parallelism:     getEnvInt("ARGON2_PARALLELISM", 2),
+		BcryptCost:            getEnvInt("BCRYPT_COST", 10),
+
+		PasswordHashConcurrency: getEnvInt("PASSWORD_HASH_CONCURRENCY", 4),
+
+		EmailVerificationTTL:      getEnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		EmailVerificationCooldown: getEnvDuration("EMAIL_VERIFICATION_COOLDOWN", time.Minute),
+
+		UsernameChangeCooldown: getEnvDuration("USERNAME_CHANGE_COOLDOWN", 30*24*time.Hour),
+
+		AccountDeletionGrace:    getEnvDuration("ACCOUNT_DELETION_GRACE", 7*24*time.Hour),
+		AccountDeletionMessages: getEnv("ACCOUNT_DELETION_MESSAGES", "anonymize"),
+
+		OIDCIssuerURL:     getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:      getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:   getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:        getEnv("OIDC_SCOPES", "openid email profile"),
+		OIDCAutoProvision: getEnvBool("OIDC_AUTO_PROVISION", true),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "file"),
+		MailFrom:     getEnv("MAIL_FROM", "go-chat <no-reply@localhost>"),
+		MailDir:      getEnv("MAIL_DIR", "mail"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+	}
+
+	if cfg.OIDCRedirectURL == "" {
+		cfg.OIDCRedirectURL = cfg.AppBaseURL + "/api/oidc/callback"
 	}
 
 	return cfg
@@ -42,3 +165,56 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvList splits a comma-separated variable, dropping empty entries.
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("invalid duration in %s=%q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("invalid integer in %s=%q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("invalid boolean in %s=%q, using %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
@@ -0,0 +1,191 @@
+package contact
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service}
+}
+
+func (h *Handler) GetContactsHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	contacts, err := h.service.ListContacts(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, contacts)
+}
+
+func (h *Handler) RemoveContactHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	contactID, err := strconv.ParseInt(ctx.Param("userID"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.service.Remove(ctx.Request.Context(), userID, contactID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) GetRequestsHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	requests, err := h.service.ListRequests(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, requests)
+}
+
+func (h *Handler) SendRequestHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var input SendRequestInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req, err := h.service.SendRequest(ctx.Request.Context(), userID, input)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	if req.Status == RequestStatusAccepted {
+		ctx.JSON(http.StatusOK, req)
+		return
+	}
+	ctx.JSON(http.StatusCreated, req)
+}
+
+func (h *Handler) AcceptRequestHandler(ctx *gin.Context) {
+	userID, requestID, ok := currentUserAndRequest(ctx)
+	if !ok {
+		return
+	}
+
+	req, err := h.service.Accept(ctx.Request.Context(), userID, requestID)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, req)
+}
+
+func (h *Handler) DeclineRequestHandler(ctx *gin.Context) {
+	userID, requestID, ok := currentUserAndRequest(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Decline(ctx.Request.Context(), userID, requestID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) CancelRequestHandler(ctx *gin.Context) {
+	userID, requestID, ok := currentUserAndRequest(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Cancel(ctx.Request.Context(), userID, requestID); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func writeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrCannotAddSelf):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserBlocked):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrRequestNotFound), errors.Is(err, ErrContactNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrAlreadyContacts), errors.Is(err, ErrRequestExists):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func currentUserAndRequest(ctx *gin.Context) (int64, int64, bool) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return 0, 0, false
+	}
+
+	requestID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid request id"})
+		return 0, 0, false
+	}
+	return userID, requestID, true
+}
+
+func currentUserID(ctx *gin.Context) (int64, bool) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return 0, false
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func RegisterRoutes(r *gin.RouterGroup, h *Handler) {
+	contacts := r.Group("/contacts")
+	{
+		contacts.GET("", h.GetContactsHandler)
+		contacts.DELETE("/:userID", h.RemoveContactHandler)
+		contacts.GET("/requests", h.GetRequestsHandler)
+		contacts.POST("/requests", h.SendRequestHandler)
+		contacts.POST("/requests/:id/accept", h.AcceptRequestHandler)
+		contacts.POST("/requests/:id/decline", h.DeclineRequestHandler)
+		contacts.DELETE("/requests/:id", h.CancelRequestHandler)
+	}
+}
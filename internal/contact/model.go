@@ -0,0 +1,60 @@
+package contact
+
+import "time"
+
+const (
+	EventContactRequest = "contact_request"
+
+	RequestStatusPending   = "pending"
+	RequestStatusAccepted  = "accepted"
+	RequestStatusCancelled = "cancelled"
+)
+
+type Request struct {
+	ID          int64
+	SenderID    int64
+	RecipientID int64
+	CreatedAt   time.Time
+}
+
+type SendRequestInput struct {
+	UserID int64 `json:"user_id" binding:"required"`
+}
+
+// UserSummary is the public part of a profile shown next to contacts and
+// requests.
+type UserSummary struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+type ContactResponse struct {
+	User  UserSummary `json:"user"`
+	Since time.Time   `json:"since"`
+}
+
+type ContactListResponse struct {
+	Contacts []ContactResponse `json:"contacts"`
+}
+
+type RequestResponse struct {
+	ID        int64       `json:"id"`
+	Status    string      `json:"status"`
+	User      UserSummary `json:"user"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type RequestListResponse struct {
+	Incoming []RequestResponse `json:"incoming"`
+	Outgoing []RequestResponse `json:"outgoing"`
+}
+
+// RequestEvent is pushed over the WebSocket to the other side of a request
+// when it is received, accepted or cancelled.
+type RequestEvent struct {
+	RequestID int64       `json:"request_id"`
+	Status    string      `json:"status"`
+	User      UserSummary `json:"user"`
+}
@@ -0,0 +1,206 @@
+package contact
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db}
+}
+
+// CreateRequest stores a pending request. It reports false if the same
+// request is already pending.
+func (r *Repository) CreateRequest(ctx context.Context, exec database.Executor, senderID, recipientID int64, createdAt time.Time) (Request, bool, error) {
+	query := `
+		INSERT INTO contact_requests (sender_id, recipient_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (sender_id, recipient_id) DO NOTHING
+		RETURNING id, sender_id, recipient_id, created_at;
+	`
+	var req Request
+	err := exec.QueryRowContext(ctx, query, senderID, recipientID, createdAt).Scan(
+		&req.ID,
+		&req.SenderID,
+		&req.RecipientID,
+		&req.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return Request{}, false, nil
+	}
+	if err != nil {
+		return Request{}, false, err
+	}
+	return req, true, nil
+}
+
+func (r *Repository) GetRequestBetween(ctx context.Context, exec database.Executor, senderID, recipientID int64) (Request, error) {
+	query := `
+		SELECT id, sender_id, recipient_id, created_at
+		FROM contact_requests
+		WHERE sender_id = $1 AND recipient_id = $2;
+	`
+	var req Request
+	err := exec.QueryRowContext(ctx, query, senderID, recipientID).Scan(
+		&req.ID,
+		&req.SenderID,
+		&req.RecipientID,
+		&req.CreatedAt,
+	)
+	return req, err
+}
+
+// DeleteRequest removes a pending request and returns it, provided the user
+// is on the given side of it.
+func (r *Repository) DeleteRequest(ctx context.Context, exec database.Executor, id int64, senderID, recipientID sql.NullInt64) (Request, error) {
+	query := `
+		DELETE FROM contact_requests
+		WHERE id = $1
+			AND ($2::bigint IS NULL OR sender_id = $2)
+			AND ($3::bigint IS NULL OR recipient_id = $3)
+		RETURNING id, sender_id, recipient_id, created_at;
+	`
+	var req Request
+	err := exec.QueryRowContext(ctx, query, id, senderID, recipientID).Scan(
+		&req.ID,
+		&req.SenderID,
+		&req.RecipientID,
+		&req.CreatedAt,
+	)
+	return req, err
+}
+
+func (r *Repository) GetIncomingRequests(ctx context.Context, exec database.Executor, userID int64) ([]RequestResponse, error) {
+	query := `
+		SELECT cr.id, u.id, u.username, u.display_name, u.avatar_url, cr.created_at
+		FROM contact_requests cr
+		JOIN users u ON u.id = cr.sender_id AND u.deleted_at IS NULL
+		WHERE cr.recipient_id = $1
+		ORDER BY cr.created_at DESC;
+	`
+	return r.queryRequests(ctx, exec, query, userID)
+}
+
+func (r *Repository) GetOutgoingRequests(ctx context.Context, exec database.Executor, userID int64) ([]RequestResponse, error) {
+	query := `
+		SELECT cr.id, u.id, u.username, u.display_name, u.avatar_url, cr.created_at
+		FROM contact_requests cr
+		JOIN users u ON u.id = cr.recipient_id AND u.deleted_at IS NULL
+		WHERE cr.sender_id = $1
+		ORDER BY cr.created_at DESC;
+	`
+	return r.queryRequests(ctx, exec, query, userID)
+}
+
+func (r *Repository) queryRequests(ctx context.Context, exec database.Executor, query string, userID int64) ([]RequestResponse, error) {
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []RequestResponse{}
+	for rows.Next() {
+		req := RequestResponse{Status: RequestStatusPending}
+		if err := rows.Scan(
+			&req.ID,
+			&req.User.ID,
+			&req.User.Username,
+			&req.User.DisplayName,
+			&req.User.AvatarURL,
+			&req.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
+
+// AddContact records the relationship for both users.
+func (r *Repository) AddContact(ctx context.Context, exec database.Executor, userA, userB int64, createdAt time.Time) error {
+	query := `
+		INSERT INTO contacts (user_id, contact_id, created_at)
+		VALUES ($1, $2, $3), ($2, $1, $3)
+		ON CONFLICT (user_id, contact_id) DO NOTHING;
+	`
+	_, err := exec.ExecContext(ctx, query, userA, userB, createdAt)
+	return err
+}
+
+func (r *Repository) RemoveContact(ctx context.Context, exec database.Executor, userA, userB int64) (bool, error) {
+	query := `
+		DELETE FROM contacts
+		WHERE (user_id = $1 AND contact_id = $2) OR (user_id = $2 AND contact_id = $1);
+	`
+	res, err := exec.ExecContext(ctx, query, userA, userB)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *Repository) AreContacts(ctx context.Context, exec database.Executor, userA, userB int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM contacts
+			WHERE user_id = $1 AND contact_id = $2
+		);
+	`
+	var contacts bool
+	err := exec.QueryRowContext(ctx, query, userA, userB).Scan(&contacts)
+	return contacts, err
+}
+
+func (r *Repository) GetContacts(ctx context.Context, exec database.Executor, userID int64) ([]ContactResponse, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, c.created_at
+		FROM contacts c
+		JOIN users u ON u.id = c.contact_id
+		WHERE c.user_id = $1 AND u.deleted_at IS NULL
+		ORDER BY LOWER(u.username);
+	`
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []ContactResponse{}
+	for rows.Next() {
+		var c ContactResponse
+		if err := rows.Scan(&c.User.ID, &c.User.Username, &c.User.DisplayName, &c.User.AvatarURL, &c.Since); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, c)
+	}
+
+	return contacts, rows.Err()
+}
+
+// GetUserSummary loads the public profile of an account that has not been
+// deleted.
+func (r *Repository) GetUserSummary(ctx context.Context, exec database.Executor, userID int64) (UserSummary, error) {
+	query := `
+		SELECT id, username, display_name, avatar_url
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+	var u UserSummary
+	err := exec.QueryRowContext(ctx, query, userID).Scan(&u.ID, &u.Username, &u.DisplayName, &u.AvatarURL)
+	return u, err
+}
@@ -0,0 +1,263 @@
+package contact
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/block"
+	"github.com/vladopadikk/go-chat/internal/database"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+var ErrCannotAddSelf = errors.New("you cannot add yourself as a contact")
+var ErrUserBlocked = errors.New("you have blocked this user")
+var ErrAlreadyContacts = errors.New("user is already a contact")
+var ErrRequestExists = errors.New("contact request is already pending")
+var ErrRequestNotFound = errors.New("contact request not found")
+var ErrContactNotFound = errors.New("contact not found")
+
+// Notifier pushes real-time events to the connected clients of a user.
+type Notifier interface {
+	NotifyUser(userID int64, event string, payload any)
+}
+
+type Service struct {
+	repo      *Repository
+	blockRepo *block.Repository
+	notifier  Notifier
+}
+
+func NewService(repo *Repository, blockRepo *block.Repository, notifier Notifier) *Service {
+	return &Service{repo, blockRepo, notifier}
+}
+
+// SendRequest asks another user to become a contact. If that user already
+// asked the sender, their request is accepted instead.
+func (s *Service) SendRequest(ctx context.Context, senderID int64, input SendRequestInput) (RequestResponse, error) {
+	if input.UserID == senderID {
+		return RequestResponse{}, ErrCannotAddSelf
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	recipient, err := s.loadVisibleUser(ctx, tx, senderID, input.UserID)
+	if err != nil {
+		return RequestResponse{}, err
+	}
+
+	contacts, err := s.repo.AreContacts(ctx, tx, senderID, recipient.ID)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if contacts {
+		return RequestResponse{}, ErrAlreadyContacts
+	}
+
+	reverse, err := s.repo.GetRequestBetween(ctx, tx, recipient.ID, senderID)
+	if err != nil && err != sql.ErrNoRows {
+		return RequestResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if err == nil {
+		return s.accept(ctx, tx, reverse.ID, sql.NullInt64{})
+	}
+
+	req, created, err := s.repo.CreateRequest(ctx, tx, senderID, recipient.ID, time.Now())
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if !created {
+		return RequestResponse{}, ErrRequestExists
+	}
+
+	sender, err := s.repo.GetUserSummary(ctx, tx, senderID)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RequestResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	s.notifier.NotifyUser(recipient.ID, EventContactRequest, RequestEvent{
+		RequestID: req.ID,
+		Status:    RequestStatusPending,
+		User:      sender,
+	})
+
+	return RequestResponse{
+		ID:        req.ID,
+		Status:    RequestStatusPending,
+		User:      recipient,
+		CreatedAt: req.CreatedAt,
+	}, nil
+}
+
+// Accept turns an incoming request into a contact on both sides.
+func (s *Service) Accept(ctx context.Context, userID, requestID int64) (RequestResponse, error) {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	return s.accept(ctx, tx, requestID, sql.NullInt64{Int64: userID, Valid: true})
+}
+
+// Decline drops an incoming request. The sender is not told.
+func (s *Service) Decline(ctx context.Context, userID, requestID int64) error {
+	_, err := s.repo.DeleteRequest(ctx, s.repo.db, requestID, sql.NullInt64{}, sql.NullInt64{Int64: userID, Valid: true})
+	if err == sql.ErrNoRows {
+		return ErrRequestNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return nil
+}
+
+// Cancel withdraws an outgoing request.
+func (s *Service) Cancel(ctx context.Context, userID, requestID int64) error {
+	req, err := s.repo.DeleteRequest(ctx, s.repo.db, requestID, sql.NullInt64{Int64: userID, Valid: true}, sql.NullInt64{})
+	if err == sql.ErrNoRows {
+		return ErrRequestNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	sender, err := s.repo.GetUserSummary(ctx, s.repo.db, userID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	s.notifier.NotifyUser(req.RecipientID, EventContactRequest, RequestEvent{
+		RequestID: req.ID,
+		Status:    RequestStatusCancelled,
+		User:      sender,
+	})
+	return nil
+}
+
+func (s *Service) ListRequests(ctx context.Context, userID int64) (RequestListResponse, error) {
+	incoming, err := s.repo.GetIncomingRequests(ctx, s.repo.db, userID)
+	if err != nil {
+		return RequestListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	outgoing, err := s.repo.GetOutgoingRequests(ctx, s.repo.db, userID)
+	if err != nil {
+		return RequestListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	return RequestListResponse{Incoming: incoming, Outgoing: outgoing}, nil
+}
+
+func (s *Service) ListContacts(ctx context.Context, userID int64) (ContactListResponse, error) {
+	contacts, err := s.repo.GetContacts(ctx, s.repo.db, userID)
+	if err != nil {
+		return ContactListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	return ContactListResponse{Contacts: contacts}, nil
+}
+
+// Remove ends the relationship for both users.
+func (s *Service) Remove(ctx context.Context, userID, contactID int64) error {
+	removed, err := s.repo.RemoveContact(ctx, s.repo.db, userID, contactID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if !removed {
+		return ErrContactNotFound
+	}
+	return nil
+}
+
+// AreContacts reports whether the two users have each other as contacts.
+func (s *Service) AreContacts(ctx context.Context, userA, userB int64) (bool, error) {
+	contacts, err := s.repo.AreContacts(ctx, s.repo.db, userA, userB)
+	if err != nil {
+		return false, fmt.Errorf("db error: %w", err)
+	}
+	return contacts, nil
+}
+
+// accept turns a request into a contact, optionally only if recipientID is
+// its recipient. Deleting the request row claims it, so when two accepts race
+// the loser waits on the row lock and then finds nothing to accept.
+func (s *Service) accept(ctx context.Context, tx *sql.Tx, requestID int64, recipientID sql.NullInt64) (RequestResponse, error) {
+	req, err := s.repo.DeleteRequest(ctx, tx, requestID, sql.NullInt64{}, recipientID)
+	if err == sql.ErrNoRows {
+		return RequestResponse{}, ErrRequestNotFound
+	}
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	now := time.Now()
+	if err := s.repo.AddContact(ctx, tx, req.SenderID, req.RecipientID, now); err != nil {
+		return RequestResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	sender, err := s.repo.GetUserSummary(ctx, tx, req.SenderID)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	recipient, err := s.repo.GetUserSummary(ctx, tx, req.RecipientID)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RequestResponse{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	s.notifier.NotifyUser(req.SenderID, EventContactRequest, RequestEvent{
+		RequestID: req.ID,
+		Status:    RequestStatusAccepted,
+		User:      recipient,
+	})
+
+	return RequestResponse{
+		ID:        req.ID,
+		Status:    RequestStatusAccepted,
+		User:      sender,
+		CreatedAt: req.CreatedAt,
+	}, nil
+}
+
+// loadVisibleUser loads the target of a request. Users who blocked the caller
+// look like they do not exist.
+func (s *Service) loadVisibleUser(ctx context.Context, exec database.Executor, userID, targetID int64) (UserSummary, error) {
+	target, err := s.repo.GetUserSummary(ctx, exec, targetID)
+	if err == sql.ErrNoRows {
+		return UserSummary{}, ErrUserNotFound
+	}
+	if err != nil {
+		return UserSummary{}, fmt.Errorf("db error: %w", err)
+	}
+
+	blocked, err := s.blockRepo.IsBlocked(ctx, exec, userID, targetID)
+	if err != nil {
+		return UserSummary{}, fmt.Errorf("db error: %w", err)
+	}
+	if blocked {
+		return UserSummary{}, ErrUserBlocked
+	}
+
+	blockedBy, err := s.blockRepo.IsBlocked(ctx, exec, targetID, userID)
+	if err != nil {
+		return UserSummary{}, fmt.Errorf("db error: %w", err)
+	}
+	if blockedBy {
+		return UserSummary{}, ErrUserNotFound
+	}
+
+	return target, nil
+}
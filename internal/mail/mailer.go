@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vladopadikk/go-chat/internal/config"
+)
+
+const (
+	DriverSMTP   = "smtp"
+	DriverFile   = "file"
+	DriverMemory = "memory"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers plain-text emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer selected by MAIL_DRIVER.
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailDriver {
+	case DriverSMTP:
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case DriverFile:
+		return NewFileMailer(cfg.MailDir, cfg.MailFrom), nil
+	case DriverMemory:
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.MailDriver)
+	}
+}
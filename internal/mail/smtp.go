@@ -0,0 +1,106 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"time"
+)
+
+// smtpTimeout bounds a whole delivery when the caller's context has no
+// earlier deadline, so that a slow server cannot stall a request.
+const smtpTimeout = 30 * time.Second
+
+type SMTPMailer struct {
+	host string
+	addr string
+	auth smtp.Auth
+	// from is the header form of MAIL_FROM, sender the bare address used as
+	// the envelope sender.
+	from   string
+	sender string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) (*SMTPMailer, error) {
+	sender, err := netmail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM %q: %w", from, err)
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		host:   host,
+		addr:   net.JoinHostPort(host, port),
+		auth:   auth,
+		from:   from,
+		sender: sender.Address,
+	}, nil
+}
+
+// Send delivers msg like smtp.SendMail, but gives up when ctx is done or
+// smtpTimeout elapses, whichever comes first.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+
+	if m.auth != nil {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(m.auth); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := client.Mail(m.sender); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(encode(m.from, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/vladopadikk/go-chat/internal/apikey"
 )
 
 const (
@@ -46,6 +47,10 @@ func (h *Handler) SendMessageHandler(ctx *gin.Context) {
 			ctx.JSON(http.StatusForbidden, gin.H{"error": "user is not a member of the chat"})
 			return
 		}
+		if errors.Is(err, ErrRecipientBlocked) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -114,10 +119,118 @@ func (h *Handler) GetMessagesHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, msgs)
 }
 
+func (h *Handler) DeleteMessageHandler(ctx *gin.Context) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	messageID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+		return
+	}
+
+	if err := h.service.DeleteMessage(ctx.Request.Context(), userID, messageID); err != nil {
+		switch {
+		case errors.Is(err, ErrMessageNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrForbidden):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "user is not a member of the chat"})
+		case errors.Is(err, ErrCannotDelete):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) MarkReadHandler(ctx *gin.Context) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var input MarkReadInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	if err := h.service.MarkRead(ctx.Request.Context(), userID, input); err != nil {
+		switch {
+		case errors.Is(err, ErrMessageNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrForbidden):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "user is not a member of the chat"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) GetReadReceiptsHandler(ctx *gin.Context) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	chatIDParam := ctx.Query("chat_id")
+	if chatIDParam == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "miss chat id param"})
+		return
+	}
+
+	chatID, err := strconv.ParseInt(chatIDParam, 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	receipts, err := h.service.GetReadReceipts(ctx.Request.Context(), chatID, userID)
+	if err != nil {
+		if errors.Is(err, ErrForbidden) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "user is not a member of the chat"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, receipts)
+}
+
 func RegisterRoutes(r *gin.RouterGroup, h *Handler) {
 	chats := r.Group("/messages")
 	{
-		chats.POST("/send", h.SendMessageHandler)
-		chats.GET("/get", h.GetMessagesHandler)
+		apikey.Handle(chats, http.MethodPost, "/send", apikey.ScopeMessagesWrite, h.SendMessageHandler)
+		apikey.Handle(chats, http.MethodGet, "/get", apikey.ScopeMessagesRead, h.GetMessagesHandler)
+		apikey.Handle(chats, http.MethodPost, "/read", apikey.ScopeMessagesWrite, h.MarkReadHandler)
+		apikey.Handle(chats, http.MethodGet, "/read", apikey.ScopeMessagesRead, h.GetReadReceiptsHandler)
+		apikey.Handle(chats, http.MethodDelete, "/:id", apikey.ScopeMessagesWrite, h.DeleteMessageHandler)
 	}
 }
@@ -2,12 +2,19 @@ package messages
 
 import "time"
 
+const (
+	EventMessageDeleted = "message_deleted"
+	EventMessagesRead   = "messages_read"
+)
+
 type Message struct {
 	ID        int64
 	ChatID    int64
 	SenderID  int64
 	Content   string
 	CreatedAt time.Time
+	// Withheld messages are only shown to their sender.
+	Withheld bool `json:"-"`
 }
 
 type SendMessageInput struct {
@@ -26,3 +33,27 @@ type MessageResponse struct {
 type MessageListResponse struct {
 	Messages []MessageResponse `json:"messages"`
 }
+
+// DeletedEvent tells connected members that a message was removed.
+type DeletedEvent struct {
+	ID     int64 `json:"id"`
+	ChatID int64 `json:"chat_id"`
+}
+
+type MarkReadInput struct {
+	ChatID    int64 `json:"chat_id" binding:"required"`
+	MessageID int64 `json:"message_id" binding:"required"`
+}
+
+// ReadReceipt is how far a member has read a chat. It doubles as the payload
+// of the messages_read event.
+type ReadReceipt struct {
+	ChatID    int64     `json:"chat_id"`
+	UserID    int64     `json:"user_id"`
+	MessageID int64     `json:"message_id"`
+	ReadAt    time.Time `json:"read_at"`
+}
+
+type ReadReceiptListResponse struct {
+	Receipts []ReadReceipt `json:"receipts"`
+}
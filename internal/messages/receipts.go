@@ -0,0 +1,72 @@
+package messages
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MarkRead records that the user has read the chat up to and including the
+// message. Members allowed to see the user's read receipts by their privacy
+// settings are told in real time. Marking an older message than the last one
+// read changes nothing.
+func (s *Service) MarkRead(ctx context.Context, userID int64, input MarkReadInput) error {
+	isMember, err := s.chatRepo.IsUserInChat(ctx, input.ChatID, userID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if !isMember {
+		return ErrForbidden
+	}
+
+	msg, err := s.repo.GetByID(ctx, s.repo.db, input.MessageID)
+	if err == sql.ErrNoRows || (err == nil && (msg.ChatID != input.ChatID || (msg.Withheld && msg.SenderID != userID))) {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	receipt := ReadReceipt{
+		ChatID:    input.ChatID,
+		UserID:    userID,
+		MessageID: input.MessageID,
+		ReadAt:    time.Now(),
+	}
+
+	advanced, err := s.repo.UpdateLastRead(ctx, s.repo.db, receipt)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if !advanced {
+		return nil
+	}
+
+	viewers, err := s.repo.GetReceiptViewers(ctx, s.repo.db, input.ChatID, userID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	for _, viewerID := range viewers {
+		s.notifier.NotifyUser(viewerID, EventMessagesRead, receipt)
+	}
+	return nil
+}
+
+// GetReadReceipts lists how far the members of the chat have read it, leaving
+// out those whose privacy settings hide their receipts from the viewer.
+func (s *Service) GetReadReceipts(ctx context.Context, chatID, viewerID int64) (ReadReceiptListResponse, error) {
+	isMember, err := s.chatRepo.IsUserInChat(ctx, chatID, viewerID)
+	if err != nil {
+		return ReadReceiptListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if !isMember {
+		return ReadReceiptListResponse{}, ErrForbidden
+	}
+
+	receipts, err := s.repo.GetReadReceipts(ctx, s.repo.db, chatID, viewerID)
+	if err != nil {
+		return ReadReceiptListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	return ReadReceiptListResponse{Receipts: receipts}, nil
+}
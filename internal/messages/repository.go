@@ -17,31 +17,69 @@ func NewRepository(db *sql.DB) *Repository {
 
 func (r *Repository) Create(ctx context.Context, exec database.Executor, msg Message) (Message, error) {
 	query := `
-		INSERT INTO messages (chat_id, sender_id, content)
-		VALUES ($1, $2, $3)
-		RETURNING id, chat_id, sender_id, content, created_at
+		INSERT INTO messages (chat_id, sender_id, content, withheld)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, chat_id, sender_id, content, created_at, withheld
 	`
 	var message Message
 
-	err := exec.QueryRowContext(ctx, query, msg.ChatID, msg.SenderID, msg.Content).Scan(
+	err := exec.QueryRowContext(ctx, query, msg.ChatID, msg.SenderID, msg.Content, msg.Withheld).Scan(
 		&message.ID,
 		&message.ChatID,
 		&message.SenderID,
 		&message.Content,
 		&message.CreatedAt,
+		&message.Withheld,
 	)
 	return message, err
 }
 
-func (r *Repository) GetMsgByChatID(ctx context.Context, exec database.Executor, chatID int64, limit int, offset int) ([]MessageResponse, error) {
+func (r *Repository) GetByID(ctx context.Context, exec database.Executor, id int64) (Message, error) {
+	query := `
+		SELECT id, chat_id, sender_id, content, created_at, withheld
+		FROM messages
+		WHERE id = $1;
+	`
+	var message Message
+
+	err := exec.QueryRowContext(ctx, query, id).Scan(
+		&message.ID,
+		&message.ChatID,
+		&message.SenderID,
+		&message.Content,
+		&message.CreatedAt,
+		&message.Withheld,
+	)
+	return message, err
+}
+
+func (r *Repository) Delete(ctx context.Context, exec database.Executor, id int64) error {
+	query := `
+		DELETE FROM messages
+		WHERE id = $1;
+	`
+	_, err := exec.ExecContext(ctx, query, id)
+	return err
+}
+
+// GetMsgByChatID lists the messages of a chat as seen by the viewer, which
+// excludes messages withheld from them and messages from users they have
+// blocked.
+func (r *Repository) GetMsgByChatID(ctx context.Context, exec database.Executor, chatID, viewerID int64, limit int, offset int) ([]MessageResponse, error) {
 	query := `
 		SELECT m.id, m.chat_id, m.sender_id, m.content, m.created_at 
 		FROM messages m
 		WHERE m.chat_id = $1
+			AND (NOT m.withheld OR m.sender_id = $2)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM user_blocks b
+				WHERE b.blocker_id = $2 AND b.blocked_id = m.sender_id
+			)
 		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3;
+		LIMIT $3 OFFSET $4;
 	`
-	rows, err := exec.QueryContext(ctx, query, chatID, limit, offset)
+	rows, err := exec.QueryContext(ctx, query, chatID, viewerID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -66,3 +104,109 @@ func (r *Repository) GetMsgByChatID(ctx context.Context, exec database.Executor,
 	return msgs, err
 
 }
+
+// UpdateLastRead moves the member's read marker forward and reports whether
+// it moved.
+func (r *Repository) UpdateLastRead(ctx context.Context, exec database.Executor, receipt ReadReceipt) (bool, error) {
+	query := `
+		UPDATE chat_members
+		SET last_read_message_id = $3, last_read_at = $4
+		WHERE chat_id = $1
+			AND user_id = $2
+			AND (last_read_message_id IS NULL OR last_read_message_id < $3);
+	`
+	res, err := exec.ExecContext(ctx, query, receipt.ChatID, receipt.UserID, receipt.MessageID, receipt.ReadAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetReceiptViewers returns the other members of the chat that may see the
+// reader's read receipts, leaving out those the reader has blocked.
+func (r *Repository) GetReceiptViewers(ctx context.Context, exec database.Executor, chatID, readerID int64) ([]int64, error) {
+	query := `
+		SELECT peer.user_id
+		FROM chat_members peer
+		JOIN users u ON u.id = $2
+		WHERE peer.chat_id = $1
+			AND peer.user_id <> $2
+			AND (
+				u.privacy_read_receipts = 'everyone'
+				OR (u.privacy_read_receipts = 'contacts' AND EXISTS (
+					SELECT 1
+					FROM contacts c
+					WHERE c.user_id = $2 AND c.contact_id = peer.user_id
+				))
+			)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM user_blocks b
+				WHERE b.blocker_id = $2 AND b.blocked_id = peer.user_id
+			);
+	`
+	rows, err := exec.QueryContext(ctx, query, chatID, readerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var viewers []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		viewers = append(viewers, id)
+	}
+
+	return viewers, rows.Err()
+}
+
+// GetReadReceipts returns the read markers of the chat's members that the
+// viewer may see. The viewer's own marker is always included.
+func (r *Repository) GetReadReceipts(ctx context.Context, exec database.Executor, chatID, viewerID int64) ([]ReadReceipt, error) {
+	query := `
+		SELECT cm.chat_id, cm.user_id, cm.last_read_message_id, cm.last_read_at
+		FROM chat_members cm
+		JOIN users u ON u.id = cm.user_id
+		WHERE cm.chat_id = $1
+			AND cm.last_read_message_id IS NOT NULL
+			AND (
+				u.id = $2
+				OR u.privacy_read_receipts = 'everyone'
+				OR (u.privacy_read_receipts = 'contacts' AND EXISTS (
+					SELECT 1
+					FROM contacts c
+					WHERE c.user_id = u.id AND c.contact_id = $2
+				))
+			)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM user_blocks b
+				WHERE b.blocker_id = u.id AND b.blocked_id = $2
+			)
+		ORDER BY cm.user_id;
+	`
+	rows, err := exec.QueryContext(ctx, query, chatID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipts := []ReadReceipt{}
+	for rows.Next() {
+		var receipt ReadReceipt
+		if err := rows.Scan(&receipt.ChatID, &receipt.UserID, &receipt.MessageID, &receipt.ReadAt); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	return receipts, rows.Err()
+}
@@ -2,22 +2,41 @@ package messages
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 
+	"github.com/vladopadikk/go-chat/internal/block"
 	"github.com/vladopadikk/go-chat/internal/chat"
 )
 
 var ErrForbidden = errors.New("user is not a member of the chat")
 var ErrChatNotFound = errors.New("chat not found")
+var ErrRecipientBlocked = errors.New("you have blocked this user")
+var ErrMessageNotFound = errors.New("message not found")
+var ErrCannotDelete = errors.New("you can only delete your own messages")
+
+// Authorizer checks what a member's role in a chat allows them to do.
+type Authorizer interface {
+	CheckPermission(ctx context.Context, chatID, userID int64, perm chat.Permission) error
+}
+
+// Notifier pushes real-time events to connected clients.
+type Notifier interface {
+	NotifyUser(userID int64, event string, payload any)
+	NotifyChat(chatID int64, event string, payload any)
+}
 
 type Service struct {
-	repo     *Repository
-	chatRepo *chat.Repository
+	repo       *Repository
+	chatRepo   *chat.Repository
+	blockRepo  *block.Repository
+	authorizer Authorizer
+	notifier   Notifier
 }
 
-func NewService(repo *Repository, chatRepo *chat.Repository) *Service {
-	return &Service{repo, chatRepo}
+func NewService(repo *Repository, chatRepo *chat.Repository, blockRepo *block.Repository, authorizer Authorizer, notifier Notifier) *Service {
+	return &Service{repo, chatRepo, blockRepo, authorizer, notifier}
 }
 
 func (s *Service) SendMessage(ctx context.Context, senderID int64, input SendMessageInput) (Message, error) {
@@ -43,10 +62,33 @@ func (s *Service) SendMessage(ctx context.Context, senderID int64, input SendMes
 		return Message{}, ErrForbidden
 	}
 
+	// In a private chat with someone who blocked the sender the message is
+	// accepted as usual but only ever shown to the sender.
+	withheld := false
+	peerID, private, err := s.chatRepo.GetPrivatePeer(ctx, tx, input.ChatID, senderID)
+	if err != nil {
+		return Message{}, err
+	}
+	if private {
+		blocked, err := s.blockRepo.IsBlocked(ctx, tx, senderID, peerID)
+		if err != nil {
+			return Message{}, err
+		}
+		if blocked {
+			return Message{}, ErrRecipientBlocked
+		}
+
+		withheld, err = s.blockRepo.IsBlocked(ctx, tx, peerID, senderID)
+		if err != nil {
+			return Message{}, err
+		}
+	}
+
 	msg, err := s.repo.Create(ctx, tx, Message{
 		ChatID:   input.ChatID,
 		SenderID: senderID,
 		Content:  input.Content,
+		Withheld: withheld,
 	})
 	if err != nil {
 		return Message{}, err
@@ -68,7 +110,7 @@ func (s *Service) GetMessages(ctx context.Context, chatID, userID int64, limit,
 		return MessageListResponse{}, ErrForbidden
 	}
 
-	msgs, err := s.repo.GetMsgByChatID(ctx, s.repo.db, chatID, limit, offset)
+	msgs, err := s.repo.GetMsgByChatID(ctx, s.repo.db, chatID, userID, limit, offset)
 	if err != nil {
 		return MessageListResponse{}, fmt.Errorf("db error: %w", err)
 	}
@@ -77,3 +119,56 @@ func (s *Service) GetMessages(ctx context.Context, chatID, userID int64, limit,
 		Messages: msgs,
 	}, nil
 }
+
+// DeleteMessage removes a message. Members can always delete their own
+// messages; deleting someone else's needs a group role that allows it.
+func (s *Service) DeleteMessage(ctx context.Context, userID, messageID int64) error {
+	msg, err := s.repo.GetByID(ctx, s.repo.db, messageID)
+	if err == sql.ErrNoRows || (err == nil && msg.Withheld && msg.SenderID != userID) {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	isMember, err := s.chatRepo.IsUserInChat(ctx, msg.ChatID, userID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if !isMember {
+		return ErrForbidden
+	}
+
+	if msg.SenderID != userID {
+		err := s.authorizer.CheckPermission(ctx, msg.ChatID, userID, chat.PermissionDeleteMessages)
+		if errors.Is(err, chat.ErrPermissionDenied) || errors.Is(err, chat.ErrNotGroupChat) {
+			return ErrCannotDelete
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.Delete(ctx, s.repo.db, messageID); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	// A withheld message was only ever shown to its sender.
+	event := DeletedEvent{ID: msg.ID, ChatID: msg.ChatID}
+	if msg.Withheld {
+		s.notifier.NotifyUser(msg.SenderID, EventMessageDeleted, event)
+	} else {
+		s.notifier.NotifyChat(msg.ChatID, EventMessageDeleted, event)
+	}
+	return nil
+}
+
+// HiddenFrom returns the members of the chat that must not receive the
+// sender's messages in real time because they have blocked the sender.
+func (s *Service) HiddenFrom(ctx context.Context, chatID, senderID int64) ([]int64, error) {
+	blockers, err := s.blockRepo.GetBlockersInChat(ctx, s.repo.db, chatID, senderID)
+	if err != nil {
+		return nil, fmt.Errorf("db error: %w", err)
+	}
+	return blockers, nil
+}
@@ -0,0 +1,76 @@
+package oidc
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vladopadikk/go-chat/internal/auth"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service}
+}
+
+func (h *Handler) LoginHandler(ctx *gin.Context) {
+	redirectURL, state, err := h.service.StartLogin(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	http.SetCookie(ctx.Writer, h.service.StateCookie(state, int(loginStateTTL.Seconds())))
+	ctx.Redirect(http.StatusFound, redirectURL)
+}
+
+func (h *Handler) CallbackHandler(ctx *gin.Context) {
+	if providerErr := ctx.Query("error"); providerErr != "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": providerErr, "error_description": ctx.Query("error_description")})
+		return
+	}
+
+	code := ctx.Query("code")
+	state := ctx.Query("state")
+	if code == "" || state == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "miss code or state param"})
+		return
+	}
+
+	// The state cookie is single-use like the state itself.
+	cookieState, _ := ctx.Cookie(StateCookieName)
+	http.SetCookie(ctx.Writer, h.service.StateCookie("", -1))
+
+	resp, err := h.service.Callback(ctx.Request.Context(), code, state, cookieState, auth.ClientInfo(ctx))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidState):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, ErrInvalidIDToken), errors.Is(err, ErrEmailNotVerified):
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, ErrAccountNotLinked), errors.Is(err, auth.ErrAccountSuspended):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, ErrUnverifiedLocalAccount):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
+	oidc := router.Group("/oidc")
+	{
+		oidc.GET("/login", handler.LoginHandler)
+		oidc.GET("/callback", handler.CallbackHandler)
+	}
+}
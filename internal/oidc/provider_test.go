@@ -0,0 +1,277 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "go-chat"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://localhost:8080/api/oidc/callback"
+	testCode         = "auth-code"
+	testVerifier     = "code-verifier"
+	testNonce        = "nonce"
+	testKID          = "key-1"
+)
+
+// mockProvider is a minimal OpenID Connect provider serving discovery, JWKS
+// and a token endpoint that hands out idToken for testCode.
+type mockProvider struct {
+	server  *httptest.Server
+	key     *rsa.PrivateKey
+	idToken string
+
+	// issuer overrides the issuer advertised by discovery when set.
+	issuer string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	m := &mockProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := m.server.URL
+		if m.issuer != "" {
+			issuer = m.issuer
+		}
+		writeJSON(w, http.StatusOK, discoveryDocument{
+			Issuer:                issuer,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, jsonWebKeySet{Keys: []jsonWebKey{{
+			Kty: "RSA",
+			Kid: testKID,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || clientID != testClientID || secret != testClientSecret {
+			writeJSON(w, http.StatusUnauthorized, tokenEndpointResponse{Error: "invalid_client"})
+			return
+		}
+		if r.PostFormValue("grant_type") != "authorization_code" ||
+			r.PostFormValue("code") != testCode ||
+			r.PostFormValue("code_verifier") != testVerifier ||
+			r.PostFormValue("redirect_uri") != testRedirectURL {
+			writeJSON(w, http.StatusBadRequest, tokenEndpointResponse{Error: "invalid_grant"})
+			return
+		}
+		writeJSON(w, http.StatusOK, tokenEndpointResponse{IDToken: m.idToken})
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+func (m *mockProvider) provider() *Provider {
+	return NewProvider(m.server.URL, testClientID, testClientSecret, testRedirectURL, "openid email", m.server.Client())
+}
+
+func (m *mockProvider) claims() IDTokenClaims {
+	now := time.Now()
+	return IDTokenClaims{
+		Nonce:         testNonce,
+		Email:         "ivan@example.com",
+		EmailVerified: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.server.URL,
+			Subject:   "subject-1",
+			Audience:  jwt.ClaimStrings{testClientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func (m *mockProvider) sign(t *testing.T, claims IDTokenClaims, key *rsa.PrivateKey, kid string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign id token: %v", err)
+	}
+	return signed
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestProviderAuthCodeURL(t *testing.T) {
+	m := newMockProvider(t)
+
+	rawURL, err := m.provider().AuthCodeURL(context.Background(), "state", testNonce, "challenge")
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != m.server.URL+"/authorize" {
+		t.Fatalf("endpoint = %q, want %q", got, m.server.URL+"/authorize")
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"state":                 "state",
+		"nonce":                 testNonce,
+		"code_challenge":        "challenge",
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := u.Query().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestProviderDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockProvider(t)
+	m.issuer = "https://evil.example.com"
+
+	if _, err := m.provider().AuthCodeURL(context.Background(), "state", testNonce, "challenge"); err == nil {
+		t.Fatal("AuthCodeURL() accepted a discovery document for another issuer")
+	}
+}
+
+func TestProviderExchange(t *testing.T) {
+	m := newMockProvider(t)
+	m.idToken = "id-token"
+
+	tests := []struct {
+		name     string
+		code     string
+		verifier string
+		wantErr  bool
+	}{
+		{"valid code", testCode, testVerifier, false},
+		{"unknown code", "other-code", testVerifier, true},
+		{"wrong code verifier", testCode, "other-verifier", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idToken, err := m.provider().Exchange(context.Background(), tt.code, tt.verifier)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Exchange() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if idToken != m.idToken {
+				t.Fatalf("Exchange() = %q, want %q", idToken, m.idToken)
+			}
+		})
+	}
+}
+
+func TestProviderVerifyIDToken(t *testing.T) {
+	m := newMockProvider(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	withClaims := func(edit func(*IDTokenClaims)) string {
+		claims := m.claims()
+		edit(&claims)
+		return m.sign(t, claims, m.key, testKID)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		nonce   string
+		wantErr bool
+	}{
+		{"valid", withClaims(func(c *IDTokenClaims) {}), testNonce, false},
+		{"bad nonce", withClaims(func(c *IDTokenClaims) {}), "other-nonce", true},
+		{"missing nonce", withClaims(func(c *IDTokenClaims) { c.Nonce = "" }), testNonce, true},
+		{"wrong aud", withClaims(func(c *IDTokenClaims) { c.Audience = jwt.ClaimStrings{"someone-else"} }), testNonce, true},
+		{"wrong azp", withClaims(func(c *IDTokenClaims) {
+			c.Audience = jwt.ClaimStrings{testClientID, "someone-else"}
+			c.AuthorizedParty = "someone-else"
+		}), testNonce, true},
+		{"expired", withClaims(func(c *IDTokenClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }), testNonce, true},
+		{"missing expiry", withClaims(func(c *IDTokenClaims) { c.ExpiresAt = nil }), testNonce, true},
+		{"wrong issuer", withClaims(func(c *IDTokenClaims) { c.Issuer = "https://evil.example.com" }), testNonce, true},
+		{"missing subject", withClaims(func(c *IDTokenClaims) { c.Subject = "" }), testNonce, true},
+		{"signed by another key", m.sign(t, m.claims(), otherKey, testKID), testNonce, true},
+		{"unknown kid", m.sign(t, m.claims(), m.key, "key-2"), testNonce, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := m.provider().VerifyIDToken(context.Background(), tt.token, tt.nonce)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("VerifyIDToken() error = %v, want %v", err, ErrInvalidIDToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyIDToken() error = %v", err)
+			}
+			if claims.Subject != "subject-1" || claims.Email != "ivan@example.com" {
+				t.Fatalf("VerifyIDToken() claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestProviderExchangeAndVerify(t *testing.T) {
+	m := newMockProvider(t)
+	m.idToken = m.sign(t, m.claims(), m.key, testKID)
+
+	p := m.provider()
+
+	idToken, err := p.Exchange(context.Background(), testCode, testVerifier)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if _, err := p.VerifyIDToken(context.Background(), idToken, testNonce); err != nil {
+		t.Fatalf("VerifyIDToken() error = %v", err)
+	}
+}
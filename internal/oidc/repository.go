@@ -0,0 +1,73 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db}
+}
+
+func (r *Repository) CreateState(ctx context.Context, exec database.Executor, state LoginState) error {
+	query := `
+		INSERT INTO oidc_login_states (state, code_verifier, nonce, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := exec.ExecContext(ctx, query, state.State, state.CodeVerifier, state.Nonce, state.ExpiresAt)
+	return err
+}
+
+// ConsumeState deletes and returns a login state so that it can be used once.
+func (r *Repository) ConsumeState(ctx context.Context, exec database.Executor, state string) (LoginState, error) {
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE state = $1
+		RETURNING state, code_verifier, nonce, expires_at, created_at;
+	`
+	var s LoginState
+	err := exec.QueryRowContext(ctx, query, state).Scan(&s.State, &s.CodeVerifier, &s.Nonce, &s.ExpiresAt, &s.CreatedAt)
+	return s, err
+}
+
+func (r *Repository) DeleteExpiredStates(ctx context.Context, exec database.Executor, now time.Time) error {
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE expires_at <= $1;
+	`
+	_, err := exec.ExecContext(ctx, query, now)
+	return err
+}
+
+func (r *Repository) GetIdentity(ctx context.Context, exec database.Executor, issuer, subject string) (Identity, error) {
+	query := `
+		SELECT issuer, subject, user_id, email, created_at
+		FROM user_identities
+		WHERE issuer = $1 AND subject = $2;
+	`
+	var identity Identity
+	err := exec.QueryRowContext(ctx, query, issuer, subject).Scan(
+		&identity.Issuer,
+		&identity.Subject,
+		&identity.UserID,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	return identity, err
+}
+
+func (r *Repository) CreateIdentity(ctx context.Context, exec database.Executor, identity Identity) error {
+	query := `
+		INSERT INTO user_identities (issuer, subject, user_id, email)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := exec.ExecContext(ctx, query, identity.Issuer, identity.Subject, identity.UserID, identity.Email)
+	return err
+}
@@ -0,0 +1,239 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/auth"
+	"github.com/vladopadikk/go-chat/internal/config"
+	"github.com/vladopadikk/go-chat/internal/session"
+	"github.com/vladopadikk/go-chat/internal/user"
+	"github.com/vladopadikk/go-chat/internal/validation"
+)
+
+const (
+	loginStateTTL = 10 * time.Minute
+
+	// StateCookieName binds a login state to the browser that started the
+	// login, so that nobody can finish it with a victim's browser.
+	StateCookieName = "oidc_state"
+)
+
+var ErrInvalidState = errors.New("invalid or expired login state")
+var ErrEmailNotVerified = errors.New("identity provider did not verify the email address")
+var ErrAccountNotLinked = errors.New("no account is linked to this identity")
+var ErrUnverifiedLocalAccount = errors.New("an unverified account with this email already exists")
+
+type Service struct {
+	repo        *Repository
+	provider    *Provider
+	userRepo    *user.Repository
+	authService *auth.Service
+	cfg         *config.Config
+}
+
+func NewService(repo *Repository, provider *Provider, userRepo *user.Repository, authService *auth.Service, cfg *config.Config) *Service {
+	return &Service{repo, provider, userRepo, authService, cfg}
+}
+
+// StartLogin remembers a fresh state, nonce and PKCE verifier and returns the
+// provider URL the browser has to be sent to together with the state, which
+// the caller must also hand to the browser in the state cookie.
+func (s *Service) StartLogin(ctx context.Context) (string, string, error) {
+	state, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomString(48)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+
+	if err := s.repo.DeleteExpiredStates(ctx, s.repo.db, now); err != nil {
+		log.Printf("failed to delete expired oidc states: %v", err)
+	}
+
+	err = s.repo.CreateState(ctx, s.repo.db, LoginState{
+		State:        state,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    now.Add(loginStateTTL),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("db error: %w", err)
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	redirectURL, err := s.provider.AuthCodeURL(ctx, state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		return "", "", err
+	}
+	return redirectURL, state, nil
+}
+
+// Callback finishes the authorization code flow, resolves the local account
+// for the identity and signs it in. cookieState is the state cookie sent by
+// the browser and must match state. Accounts with two-factor authentication
+// get a challenge token, exactly like a password login.
+func (s *Service) Callback(ctx context.Context, code, state, cookieState string, client session.ClientInfo) (auth.LoginResponse, error) {
+	if cookieState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		return auth.LoginResponse{}, ErrInvalidState
+	}
+
+	loginState, err := s.repo.ConsumeState(ctx, s.repo.db, state)
+	if err == sql.ErrNoRows {
+		return auth.LoginResponse{}, ErrInvalidState
+	}
+	if err != nil {
+		return auth.LoginResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if time.Now().After(loginState.ExpiresAt) {
+		return auth.LoginResponse{}, ErrInvalidState
+	}
+
+	rawIDToken, err := s.provider.Exchange(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		return auth.LoginResponse{}, err
+	}
+
+	claims, err := s.provider.VerifyIDToken(ctx, rawIDToken, loginState.Nonce)
+	if err != nil {
+		return auth.LoginResponse{}, err
+	}
+
+	userID, err := s.resolveUser(ctx, claims)
+	if err != nil {
+		return auth.LoginResponse{}, err
+	}
+
+	return s.authService.IssueSession(ctx, userID, client)
+}
+
+// StateCookie returns the cookie carrying state, scoped to the callback path.
+// It has to be SameSite=Lax rather than Strict: the callback is a top-level
+// navigation coming from the provider's site. A negative maxAge deletes it.
+func (s *Service) StateCookie(state string, maxAge int) *http.Cookie {
+	path := "/"
+	secure := false
+	if u, err := url.Parse(s.cfg.OIDCRedirectURL); err == nil {
+		if u.Path != "" {
+			path = u.Path
+		}
+		secure = u.Scheme == "https"
+	}
+
+	return &http.Cookie{
+		Name:     StateCookieName,
+		Value:    state,
+		Path:     path,
+		MaxAge:   maxAge,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// resolveUser returns the account linked to the identity, linking an existing
+// account with the same verified email or creating one when allowed.
+func (s *Service) resolveUser(ctx context.Context, claims *IDTokenClaims) (int64, error) {
+	issuer := s.provider.Issuer()
+
+	identity, err := s.repo.GetIdentity(ctx, s.repo.db, issuer, claims.Subject)
+	if err == nil {
+		return identity.UserID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("db error: %w", err)
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return 0, ErrEmailNotVerified
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int64
+
+	existing, err := s.userRepo.GetByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		// Linking to an account nobody has proven to own would let whoever
+		// registered it first take over the identity.
+		if !existing.EmailVerifiedAt.Valid {
+			return 0, ErrUnverifiedLocalAccount
+		}
+		userID = existing.ID
+
+	case err == sql.ErrNoRows:
+		if !s.cfg.OIDCAutoProvision {
+			return 0, ErrAccountNotLinked
+		}
+		username, err := s.userRepo.FreeUsername(ctx, tx, usernameFromClaims(claims))
+		if err != nil {
+			return 0, fmt.Errorf("db error: %w", err)
+		}
+		userID, err = s.userRepo.CreateVerified(ctx, tx, username, validation.NormalizeEmail(claims.Email), time.Now())
+		if err != nil {
+			return 0, fmt.Errorf("db error: %w", err)
+		}
+
+	default:
+		return 0, fmt.Errorf("db error: %w", err)
+	}
+
+	err = s.repo.CreateIdentity(ctx, tx, Identity{
+		Issuer:  issuer,
+		Subject: claims.Subject,
+		UserID:  userID,
+		Email:   claims.Email,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("db error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return userID, nil
+}
+
+func usernameFromClaims(claims *IDTokenClaims) string {
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Name
+	}
+	if username == "" {
+		username, _, _ = strings.Cut(claims.Email, "@")
+	}
+	return username
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,50 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vladopadikk/go-chat/internal/config"
+	"github.com/vladopadikk/go-chat/internal/session"
+)
+
+func TestCallbackRejectsBadState(t *testing.T) {
+	// A state that does not match the browser's cookie is rejected before
+	// the login state is looked up or the provider is contacted.
+	s := &Service{}
+
+	tests := []struct {
+		name        string
+		state       string
+		cookieState string
+	}{
+		{"missing cookie", "state", ""},
+		{"cookie from another login", "state", "other-state"},
+		{"prefix of cookie", "state", "state-and-more"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Callback(context.Background(), testCode, tt.state, tt.cookieState, session.ClientInfo{})
+			if !errors.Is(err, ErrInvalidState) {
+				t.Fatalf("Callback() error = %v, want %v", err, ErrInvalidState)
+			}
+		})
+	}
+}
+
+func TestStateCookie(t *testing.T) {
+	s := &Service{cfg: &config.Config{OIDCRedirectURL: "https://chat.example.com/api/oidc/callback"}}
+
+	cookie := s.StateCookie("state", 600)
+
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("cookie = %+v, want HttpOnly, Secure and SameSite=Lax", cookie)
+	}
+	if cookie.Path != "/api/oidc/callback" || !strings.HasPrefix(cookie.String(), StateCookieName+"=state") {
+		t.Fatalf("cookie = %q", cookie.String())
+	}
+}
@@ -0,0 +1,224 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/vladopadikk/go-chat/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var ErrUnsupportedAlgorithm = errors.New("unsupported password hashing algorithm")
+var ErrMalformedHash = errors.New("malformed password hash")
+
+// Argon2Params are the tunable argon2id parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Hasher hashes new passwords with the configured algorithm and verifies
+// hashes produced by any supported one. Hashes are self-describing: argon2id
+// uses the PHC string format and bcrypt its usual $2a$/$2b$ form.
+type Hasher struct {
+	algorithm  string
+	argon2     Argon2Params
+	bcryptCost int
+
+	// slots bounds how many hashes are computed at once; each argon2id run
+	// holds Memory KiB, so a burst of logins could otherwise exhaust memory.
+	slots chan struct{}
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+func NewHasher(cfg *config.Config) (*Hasher, error) {
+	if cfg.Argon2Parallelism > 255 {
+		return nil, fmt.Errorf("invalid argon2id parallelism %d", cfg.Argon2Parallelism)
+	}
+	if cfg.PasswordHashConcurrency < 1 {
+		return nil, fmt.Errorf("invalid password hash concurrency %d", cfg.PasswordHashConcurrency)
+	}
+
+	h := &Hasher{
+		algorithm: cfg.PasswordHashAlgorithm,
+		argon2: Argon2Params{
+			Memory:      uint32(cfg.Argon2Memory),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+		bcryptCost: cfg.BcryptCost,
+		slots:      make(chan struct{}, cfg.PasswordHashConcurrency),
+	}
+
+	switch h.algorithm {
+	case AlgorithmArgon2id:
+		if h.argon2.Memory < 8*uint32(h.argon2.Parallelism) || h.argon2.Iterations < 1 || h.argon2.Parallelism < 1 {
+			return nil, fmt.Errorf("invalid argon2id parameters: m=%d t=%d p=%d", h.argon2.Memory, h.argon2.Iterations, h.argon2.Parallelism)
+		}
+	case AlgorithmBcrypt:
+		if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost %d", h.bcryptCost)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, h.algorithm)
+	}
+
+	return h, nil
+}
+
+// Hash encodes a password with the configured algorithm and parameters.
+func (h *Hasher) Hash(password string) (string, error) {
+	h.acquire()
+	defer h.release()
+
+	if h.algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := h.argon2
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether the password matches the encoded hash.
+func (h *Hasher) Verify(password, encoded string) (bool, error) {
+	h.acquire()
+	defer h.release()
+
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		p, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+
+	case isBcrypt(encoded):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+		}
+		return true, nil
+	}
+
+	return false, ErrUnsupportedAlgorithm
+}
+
+// NeedsRehash reports whether the hash was made with another algorithm or
+// weaker parameters than the ones currently configured.
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	switch h.algorithm {
+	case AlgorithmArgon2id:
+		if !strings.HasPrefix(encoded, "$argon2id$") {
+			return true
+		}
+		p, _, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return true
+		}
+		return p.Memory != h.argon2.Memory ||
+			p.Iterations != h.argon2.Iterations ||
+			p.Parallelism != h.argon2.Parallelism ||
+			uint32(len(key)) != h.argon2.KeyLength
+
+	case AlgorithmBcrypt:
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.bcryptCost
+	}
+
+	return false
+}
+
+// VerifyDummy spends as long as a real verification so that callers can hide
+// whether an account exists.
+func (h *Hasher) VerifyDummy(password string) {
+	h.dummyOnce.Do(func() {
+		h.dummyHash, _ = h.Hash("dummy password")
+	})
+	h.Verify(password, h.dummyHash)
+}
+
+// acquire waits for a free hashing slot.
+func (h *Hasher) acquire() {
+	h.slots <- struct{}{}
+}
+
+func (h *Hasher) release() {
+	<-h.slots
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+	if p.Iterations < 1 || p.Parallelism < 1 {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,157 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vladopadikk/go-chat/internal/config"
+)
+
+// bcryptMaxBytes is the longest input bcrypt looks at; anything after it
+// would be silently ignored.
+const bcryptMaxBytes = 72
+
+var ErrTooShort = errors.New("password is too short")
+var ErrTooLong = errors.New("password is too long")
+var ErrTooSimple = errors.New("password needs more kinds of characters")
+var ErrBreached = errors.New("password appears in a list of breached passwords")
+var ErrPersonalInfo = errors.New("password must not contain your username or email")
+
+//go:embed breached.txt
+var builtinBreached string
+
+// Policy decides which new passwords are acceptable.
+type Policy struct {
+	minLength  int
+	maxLength  int
+	minClasses int
+	breached   map[string]struct{}
+}
+
+// NewPolicy builds the policy from config. The breached-password list is read
+// from PASSWORD_BREACHED_LIST when set, one password per line, and falls back
+// to a small built-in list of the most common passwords.
+func NewPolicy(cfg *config.Config) (*Policy, error) {
+	p := &Policy{
+		minLength:  cfg.PasswordMinLength,
+		maxLength:  cfg.PasswordMaxLength,
+		minClasses: cfg.PasswordMinClasses,
+	}
+
+	if cfg.PasswordHashAlgorithm == AlgorithmBcrypt && p.maxLength > bcryptMaxBytes {
+		p.maxLength = bcryptMaxBytes
+	}
+	if p.minLength > p.maxLength {
+		return nil, fmt.Errorf("password minimum length %d exceeds maximum %d", p.minLength, p.maxLength)
+	}
+	if p.minClasses > 4 {
+		return nil, fmt.Errorf("password character classes must be at most 4, got %d", p.minClasses)
+	}
+
+	var list io.Reader = strings.NewReader(builtinBreached)
+	if cfg.PasswordBreachedList != "" {
+		f, err := os.Open(cfg.PasswordBreachedList)
+		if err != nil {
+			return nil, fmt.Errorf("open breached password list: %w", err)
+		}
+		defer f.Close()
+		list = f
+	}
+
+	breached, err := readBreachedList(list)
+	if err != nil {
+		return nil, fmt.Errorf("read breached password list: %w", err)
+	}
+	p.breached = breached
+
+	return p, nil
+}
+
+// Validate checks a new password. personal holds values such as the username
+// and email that the password must not contain.
+func (p *Policy) Validate(password string, personal ...string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		return fmt.Errorf("%w: use at least %d characters", ErrTooShort, p.minLength)
+	}
+	if length > p.maxLength || (p.maxLength == bcryptMaxBytes && len(password) > bcryptMaxBytes) {
+		return fmt.Errorf("%w: use at most %d characters", ErrTooLong, p.maxLength)
+	}
+
+	if classes := characterClasses(password); classes < p.minClasses {
+		return fmt.Errorf("%w: use at least %d of lowercase, uppercase, digits and symbols", ErrTooSimple, p.minClasses)
+	}
+
+	lower := strings.ToLower(password)
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if local, _, found := strings.Cut(value, "@"); found {
+			value = local
+		}
+		if len(value) >= 3 && strings.Contains(lower, value) {
+			return ErrPersonalInfo
+		}
+	}
+
+	if _, ok := p.breached[lower]; ok {
+		return ErrBreached
+	}
+
+	return nil
+}
+
+// IsPolicyError reports whether err is a rejection by the policy rather than
+// an internal failure.
+func IsPolicyError(err error) bool {
+	return errors.Is(err, ErrTooShort) ||
+		errors.Is(err, ErrTooLong) ||
+		errors.Is(err, ErrTooSimple) ||
+		errors.Is(err, ErrBreached) ||
+		errors.Is(err, ErrPersonalInfo)
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+func readBreachedList(r io.Reader) (map[string]struct{}, error) {
+	breached := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breached[strings.ToLower(line)] = struct{}{}
+	}
+
+	return breached, scanner.Err()
+}
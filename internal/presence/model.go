@@ -0,0 +1,40 @@
+package presence
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	EventPresenceChanged = "presence_changed"
+
+	maxLookupIDs = 100
+)
+
+// Presence is the state of one user. LastSeenAt is when the user was last
+// connected; it is empty while they are online or if they never connected.
+// Hidden is set, and nothing else is filled in, when the user's privacy
+// settings do not let the viewer see their presence.
+type Presence struct {
+	UserID     int64      `json:"user_id"`
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	Hidden     bool       `json:"hidden,omitempty"`
+}
+
+type PresenceListResponse struct {
+	Presence []Presence `json:"presence"`
+}
+
+type lastSeen struct {
+	at      sql.NullTime
+	visible bool
+}
+
+// change is a transition reported by the hub, waiting to be persisted and
+// announced.
+type change struct {
+	userID int64
+	online bool
+	at     time.Time
+}
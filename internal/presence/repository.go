@@ -0,0 +1,109 @@
+package presence
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) UpdateLastSeen(ctx context.Context, exec database.Executor, userID int64, at time.Time) error {
+	query := `
+		UPDATE users
+		SET last_seen_at = $2
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+	_, err := exec.ExecContext(ctx, query, userID, at)
+	return err
+}
+
+// GetLastSeen returns the last seen time of those users that exist and have
+// not blocked the viewer, and whether their presence settings let the viewer
+// see it. Users who never connected have an invalid time.
+func (r *Repository) GetLastSeen(ctx context.Context, exec database.Executor, viewerID int64, userIDs []int64) (map[int64]lastSeen, error) {
+	query := `
+		SELECT u.id, u.last_seen_at,
+			u.id = $2
+				OR u.privacy_presence = 'everyone'
+				OR (u.privacy_presence = 'contacts' AND EXISTS (
+					SELECT 1
+					FROM contacts c
+					WHERE c.user_id = u.id AND c.contact_id = $2
+				))
+		FROM users u
+		WHERE u.id = ANY($1)
+			AND u.deleted_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1
+				FROM user_blocks b
+				WHERE b.blocker_id = u.id AND b.blocked_id = $2
+			);
+	`
+	rows, err := exec.QueryContext(ctx, query, userIDs, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int64]lastSeen)
+	for rows.Next() {
+		var id int64
+		var ls lastSeen
+		if err := rows.Scan(&id, &ls.at, &ls.visible); err != nil {
+			return nil, err
+		}
+		result[id] = ls
+	}
+
+	return result, rows.Err()
+}
+
+// GetWatchers returns the users who share at least one chat with the user and
+// may see their presence, leaving out those the user has blocked.
+func (r *Repository) GetWatchers(ctx context.Context, exec database.Executor, userID int64) ([]int64, error) {
+	query := `
+		SELECT DISTINCT peer.user_id
+		FROM chat_members own
+		JOIN users u ON u.id = own.user_id
+		JOIN chat_members peer ON peer.chat_id = own.chat_id AND peer.user_id <> own.user_id
+		WHERE own.user_id = $1
+			AND (
+				u.privacy_presence = 'everyone'
+				OR (u.privacy_presence = 'contacts' AND EXISTS (
+					SELECT 1
+					FROM contacts c
+					WHERE c.user_id = $1 AND c.contact_id = peer.user_id
+				))
+			)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM user_blocks b
+				WHERE b.blocker_id = $1 AND b.blocked_id = peer.user_id
+			);
+	`
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var watchers []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		watchers = append(watchers, id)
+	}
+
+	return watchers, rows.Err()
+}
@@ -0,0 +1,150 @@
+package presence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrTooManyIDs = errors.New("at most 100 user ids can be looked up at once")
+var ErrNoIDs = errors.New("ids is required")
+
+// Notifier pushes real-time events to the connected clients of a user.
+type Notifier interface {
+	NotifyUser(userID int64, event string, payload any)
+}
+
+// Service tracks who is online. The WebSocket hub reports when a user's first
+// connection opens and their last one closes; the changes are persisted and
+// announced to the users sharing a chat with them in the background, so the
+// hub is never held up by the database.
+type Service struct {
+	repo     *Repository
+	notifier Notifier
+
+	mu      sync.Mutex
+	online  map[int64]bool
+	pending []change
+	wake    chan struct{}
+}
+
+func NewService(repo *Repository, notifier Notifier) *Service {
+	return &Service{
+		repo:     repo,
+		notifier: notifier,
+		online:   make(map[int64]bool),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// UserOnline is called by the hub when the user opens their first connection.
+func (s *Service) UserOnline(userID int64) {
+	s.record(change{userID: userID, online: true, at: time.Now()})
+}
+
+// UserOffline is called by the hub when the user's last connection closes.
+func (s *Service) UserOffline(userID int64) {
+	s.record(change{userID: userID, online: false, at: time.Now()})
+}
+
+func (s *Service) IsOnline(userID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.online[userID]
+}
+
+// Run persists and announces presence changes in the order they happened.
+func (s *Service) Run() {
+	for range s.wake {
+		s.mu.Lock()
+		changes := s.pending
+		s.pending = nil
+		s.mu.Unlock()
+
+		for _, c := range changes {
+			if err := s.apply(context.Background(), c); err != nil {
+				log.Printf("failed to update presence of user %d: %v", c.userID, err)
+			}
+		}
+	}
+}
+
+// Lookup returns the presence of up to maxLookupIDs users. Users that do not
+// exist or have blocked the viewer are left out; users whose settings hide
+// their presence from the viewer are marked hidden.
+func (s *Service) Lookup(ctx context.Context, viewerID int64, userIDs []int64) (PresenceListResponse, error) {
+	if len(userIDs) == 0 {
+		return PresenceListResponse{}, ErrNoIDs
+	}
+	if len(userIDs) > maxLookupIDs {
+		return PresenceListResponse{}, ErrTooManyIDs
+	}
+
+	lastSeen, err := s.repo.GetLastSeen(ctx, s.repo.db, viewerID, userIDs)
+	if err != nil {
+		return PresenceListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	resp := PresenceListResponse{Presence: []Presence{}}
+	for _, id := range userIDs {
+		ls, ok := lastSeen[id]
+		if !ok {
+			continue
+		}
+		if !ls.visible {
+			resp.Presence = append(resp.Presence, Presence{UserID: id, Hidden: true})
+			continue
+		}
+
+		p := Presence{UserID: id, Online: s.IsOnline(id)}
+		if !p.Online && ls.at.Valid {
+			t := ls.at.Time
+			p.LastSeenAt = &t
+		}
+		resp.Presence = append(resp.Presence, p)
+	}
+
+	return resp, nil
+}
+
+func (s *Service) record(c change) {
+	s.mu.Lock()
+	if c.online {
+		s.online[c.userID] = true
+	} else {
+		delete(s.online, c.userID)
+	}
+	s.pending = append(s.pending, c)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Service) apply(ctx context.Context, c change) error {
+	if err := s.repo.UpdateLastSeen(ctx, s.repo.db, c.userID, c.at); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	watchers, err := s.repo.GetWatchers(ctx, s.repo.db, c.userID)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	event := Presence{UserID: c.userID, Online: c.online}
+	if !c.online {
+		event.LastSeenAt = &c.at
+	}
+
+	for _, watcherID := range watchers {
+		if s.IsOnline(watcherID) {
+			s.notifier.NotifyUser(watcherID, EventPresenceChanged, event)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service}
+}
+
+func (h *Handler) GetSessionsHandler(ctx *gin.Context) {
+	userID, sessionID, ok := currentSession(ctx)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.service.List(ctx.Request.Context(), userID, sessionID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+func (h *Handler) RevokeSessionHandler(ctx *gin.Context) {
+	userID, _, ok := currentSession(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+		return
+	}
+
+	if err := h.service.Revoke(ctx.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) LogoutHandler(ctx *gin.Context) {
+	userID, sessionID, ok := currentSession(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Revoke(ctx.Request.Context(), userID, sessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func currentSession(ctx *gin.Context) (int64, int64, bool) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return 0, 0, false
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return 0, 0, false
+	}
+
+	sessionID, _ := ctx.Get("sessionID")
+	id, _ := sessionID.(int64)
+
+	return userID, id, true
+}
+
+func RegisterRoutes(r *gin.RouterGroup, h *Handler) {
+	sessions := r.Group("/sessions")
+	{
+		sessions.GET("", h.GetSessionsHandler)
+		sessions.DELETE("/:id", h.RevokeSessionHandler)
+	}
+	r.POST("/logout", h.LogoutHandler)
+}
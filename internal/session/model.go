@@ -0,0 +1,34 @@
+package session
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Session struct {
+	ID         int64
+	UserID     int64
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	RevokedAt  sql.NullTime
+}
+
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+type SessionResponse struct {
+	ID         int64     `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+type SessionListResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
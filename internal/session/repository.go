@@ -0,0 +1,140 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db}
+}
+
+func (r *Repository) Create(ctx context.Context, exec database.Executor, userID int64, client ClientInfo) (Session, error) {
+	query := `
+		INSERT INTO sessions (user_id, user_agent, ip_address)
+		VALUES ($1, $2, $3)
+		RETURNING id, user_id, user_agent, ip_address, created_at, last_used_at
+	`
+	var session Session
+	err := exec.QueryRowContext(ctx, query, userID, client.UserAgent, client.IPAddress).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+	)
+	return session, err
+}
+
+// Touch records activity on a session and reports whether it is still active
+// and belongs to an account that is not suspended.
+func (r *Repository) Touch(ctx context.Context, exec database.Executor, sessionID, userID int64, usedAt time.Time) (bool, error) {
+	query := `
+		UPDATE sessions s
+		SET last_used_at = $3
+		FROM users u
+		WHERE s.id = $1
+			AND s.user_id = $2
+			AND s.revoked_at IS NULL
+			AND u.id = s.user_id
+			AND u.suspended_at IS NULL;
+	`
+	res, err := exec.ExecContext(ctx, query, sessionID, userID, usedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *Repository) GetActive(ctx context.Context, exec database.Executor, sessionID, userID int64) (Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip_address, created_at, last_used_at
+		FROM sessions
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+	`
+	var session Session
+	err := exec.QueryRowContext(ctx, query, sessionID, userID).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+	)
+	return session, err
+}
+
+func (r *Repository) GetActiveByUserID(ctx context.Context, exec database.Executor, userID int64) ([]Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip_address, created_at, last_used_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_used_at DESC;
+	`
+	rows, err := exec.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *Repository) Revoke(ctx context.Context, exec database.Executor, sessionID, userID int64, revokedAt time.Time) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+	`
+	res, err := exec.ExecContext(ctx, query, sessionID, userID, revokedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RevokeAllByUserID revokes every active session of the user except the one
+// with exceptID, which may be zero to revoke them all.
+func (r *Repository) RevokeAllByUserID(ctx context.Context, exec database.Executor, userID, exceptID int64, revokedAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = $3
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL;
+	`
+	_, err := exec.ExecContext(ctx, query, userID, exceptID, revokedAt)
+	return err
+}
@@ -0,0 +1,58 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo}
+}
+
+func (s *Service) Touch(ctx context.Context, sessionID, userID int64) (bool, error) {
+	active, err := s.repo.Touch(ctx, s.repo.db, sessionID, userID, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("db error: %w", err)
+	}
+	return active, nil
+}
+
+func (s *Service) List(ctx context.Context, userID, currentSessionID int64) (SessionListResponse, error) {
+	sessions, err := s.repo.GetActiveByUserID(ctx, s.repo.db, userID)
+	if err != nil {
+		return SessionListResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	resp := SessionListResponse{Sessions: []SessionResponse{}}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *Service) Revoke(ctx context.Context, userID, sessionID int64) error {
+	revoked, err := s.repo.Revoke(ctx, s.repo.db, sessionID, userID, time.Now())
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vladopadikk/go-chat/internal/database"
+)
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 32
+
+	usernameUniqueIndex   = "idx_users_username_lower"
+	deletedUsernamePrefix = "deleted-"
+)
+
+var ErrUsernameTaken = errors.New("username is already taken")
+var ErrUsernameReserved = errors.New("username is reserved")
+var ErrUsernameCooldown = errors.New("username was changed recently, try again later")
+
+// reservedUsernames could be mistaken for the service itself or its staff.
+var reservedUsernames = map[string]bool{
+	"abuse":         true,
+	"admin":         true,
+	"administrator": true,
+	"api":           true,
+	"deleted":       true,
+	"everyone":      true,
+	"go-chat":       true,
+	"gochat":        true,
+	"help":          true,
+	"here":          true,
+	"info":          true,
+	"me":            true,
+	"mod":           true,
+	"moderator":     true,
+	"no-reply":      true,
+	"noreply":       true,
+	"null":          true,
+	"official":      true,
+	"postmaster":    true,
+	"root":          true,
+	"security":      true,
+	"staff":         true,
+	"support":       true,
+	"system":        true,
+	"undefined":     true,
+	"webmaster":     true,
+}
+
+// IsReservedUsername reports whether a username may not be registered.
+// Names of erased accounts are reserved as well.
+func IsReservedUsername(username string) bool {
+	username = strings.ToLower(username)
+	return reservedUsernames[username] || strings.HasPrefix(username, deletedUsernamePrefix)
+}
+
+// ChangeUsername gives the user a new handle, at most once per
+// USERNAME_CHANGE_COOLDOWN. Changing only the letter case is allowed.
+func (s *Service) ChangeUsername(ctx context.Context, userID int64, input ChangeUsernameInput) (MeResponse, error) {
+	if IsReservedUsername(input.Username) {
+		return MeResponse{}, ErrUsernameReserved
+	}
+
+	u, err := s.repo.GetByID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return MeResponse{}, ErrUserNotFound
+	}
+	if err != nil {
+		return MeResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if u.Username == input.Username {
+		return toMeResponse(u), nil
+	}
+
+	now := time.Now()
+	if u.UsernameChangedAt.Valid && now.Before(u.UsernameChangedAt.Time.Add(s.cfg.UsernameChangeCooldown)) {
+		return MeResponse{}, ErrUsernameCooldown
+	}
+
+	changed, err := s.repo.UpdateUsername(ctx, userID, input.Username, now, now.Add(-s.cfg.UsernameChangeCooldown))
+	if isUsernameConflict(err) {
+		return MeResponse{}, ErrUsernameTaken
+	}
+	if err != nil {
+		return MeResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if !changed {
+		return MeResponse{}, ErrUsernameCooldown
+	}
+
+	u.Username = input.Username
+	u.UsernameChangedAt = sql.NullTime{Time: now, Valid: true}
+	return toMeResponse(u), nil
+}
+
+// FreeUsername turns an arbitrary name, such as one from an identity
+// provider, into a valid username nobody has taken yet.
+func (r *Repository) FreeUsername(ctx context.Context, exec database.Executor, name string) (string, error) {
+	base := sanitizeUsername(name)
+
+	candidate := base
+	for i := 0; i < 10; i++ {
+		if !IsReservedUsername(candidate) {
+			taken, err := r.UsernameExists(ctx, exec, candidate)
+			if err != nil {
+				return "", err
+			}
+			if !taken {
+				return candidate, nil
+			}
+		}
+
+		n, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		suffix := fmt.Sprintf("-%04d", n.Int64())
+		candidate = strings.TrimRight(truncate(base, maxUsernameLength-len(suffix)), "._-") + suffix
+	}
+
+	return "", ErrUsernameTaken
+}
+
+// sanitizeUsername keeps the characters a username may contain and pads or
+// truncates the result to a valid length.
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('_')
+		}
+	}
+
+	username := strings.TrimLeft(b.String(), "._-")
+	username = truncate(username, maxUsernameLength)
+	if len(username) < minUsernameLength {
+		username = "user" + username
+	}
+	return username
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func isUsernameConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == usernameUniqueIndex
+}
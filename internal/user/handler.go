@@ -3,8 +3,11 @@ package user
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/vladopadikk/go-chat/internal/password"
+	"github.com/vladopadikk/go-chat/internal/validation"
 )
 
 type Handler struct {
@@ -19,16 +22,28 @@ func (h *Handler) RegisterHandler(ctx *gin.Context) {
 	var userIn UserInput
 
 	if err := ctx.ShouldBindJSON(&userIn); err != nil {
+		if fields := validation.FieldErrors(err); fields != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "fields": fields})
+			return
+		}
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
 		return
 	}
 
 	user, err := h.service.Register(ctx.Request.Context(), userIn)
 	if err != nil {
-		if errors.Is(err, ErrEmailExists) {
+		if errors.Is(err, ErrEmailExists) || errors.Is(err, ErrUsernameTaken) {
 			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 			return
 		}
+		if errors.Is(err, ErrUsernameReserved) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "fields": gin.H{"username": "is reserved"}})
+			return
+		}
+		if password.IsPolicyError(err) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "fields": gin.H{"password": err.Error()}})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -36,6 +51,245 @@ func (h *Handler) RegisterHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusCreated, user)
 }
 
+func (h *Handler) VerifyEmailHandler(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "miss token param"})
+		return
+	}
+
+	if err := h.service.VerifyEmail(ctx.Request.Context(), token); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidVerificationToken):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, ErrAlreadyVerified):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+func (h *Handler) ResendVerificationHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.service.ResendVerification(ctx.Request.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, ErrAlreadyVerified):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, ErrVerificationCooldown):
+			ctx.Header("Retry-After", strconv.Itoa(int(h.service.VerificationCooldown().Seconds())))
+			ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, ErrUserNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+}
+
+func (h *Handler) GetMeHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	me, err := h.service.GetMe(ctx.Request.Context(), userID)
+	if err != nil {
+		writeProfileError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, me)
+}
+
+func (h *Handler) UpdateMeHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var input UpdateProfileInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	me, err := h.service.UpdateProfile(ctx.Request.Context(), userID, input)
+	if err != nil {
+		writeProfileError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, me)
+}
+
+func (h *Handler) GetPrivacyHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	settings, err := h.service.GetPrivacy(ctx.Request.Context(), userID)
+	if err != nil {
+		writeProfileError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (h *Handler) UpdatePrivacyHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var input UpdatePrivacyInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	settings, err := h.service.UpdatePrivacy(ctx.Request.Context(), userID, input)
+	if err != nil {
+		writeProfileError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (h *Handler) ChangeUsernameHandler(ctx *gin.Context) {
+	userID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var input ChangeUsernameInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		if fields := validation.FieldErrors(err); fields != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "fields": fields})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+
+	me, err := h.service.ChangeUsername(ctx.Request.Context(), userID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUsernameReserved):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "fields": gin.H{"username": "is reserved"}})
+		case errors.Is(err, ErrUsernameTaken):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrUsernameCooldown):
+			ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			writeProfileError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, me)
+}
+
+func (h *Handler) GetUserHandler(ctx *gin.Context) {
+	viewerID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	profile, err := h.service.GetProfile(ctx.Request.Context(), viewerID, ctx.GetString("role"), id)
+	if err != nil {
+		writeProfileError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, profile)
+}
+
+func (h *Handler) SearchHandler(ctx *gin.Context) {
+	viewerID, ok := currentUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var input SearchInput
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid query params"})
+		return
+	}
+
+	resp, err := h.service.Search(ctx.Request.Context(), viewerID, input)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSearch) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func writeProfileError(ctx *gin.Context, err error) {
+	var profileErr *ProfileError
+	switch {
+	case errors.As(err, &profileErr):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "fields": gin.H{profileErr.Field: profileErr.Message}})
+	case errors.Is(err, ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func currentUserID(ctx *gin.Context) (int64, bool) {
+	userIDAny, exist := ctx.Get("userID")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+		return 0, false
+	}
+	userID, ok := userIDAny.(int64)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
+
 func RegisterRoutes(router *gin.RouterGroup, handler *Handler) {
 	router.POST("/register", handler.RegisterHandler)
+	router.GET("/verify-email", handler.VerifyEmailHandler)
+}
+
+func RegisterProtectedRoutes(router *gin.RouterGroup, handler *Handler) {
+	router.POST("/verify-email/resend", handler.ResendVerificationHandler)
+	router.GET("/me", handler.GetMeHandler)
+	router.PATCH("/me", handler.UpdateMeHandler)
+	router.PUT("/me/username", handler.ChangeUsernameHandler)
+	router.GET("/me/privacy", handler.GetPrivacyHandler)
+	router.PATCH("/me/privacy", handler.UpdatePrivacyHandler)
+	router.GET("/users/search", handler.SearchHandler)
+	router.GET("/users/:id", handler.GetUserHandler)
 }
@@ -0,0 +1,40 @@
+package user
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail rejects requests from users who have not confirmed
+// their email address yet. It must run after auth.AuthMiddleware.
+func RequireVerifiedEmail(service *Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userIDAny, exist := ctx.Get("userID")
+		if !exist {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user unauthorized"})
+			ctx.Abort()
+			return
+		}
+		userID, ok := userIDAny.(int64)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			ctx.Abort()
+			return
+		}
+
+		verified, err := service.IsEmailVerified(ctx.Request.Context(), userID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		}
+		if !verified {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "email address is not verified"})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
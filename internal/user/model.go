@@ -1,23 +1,111 @@
 package user
 
-import "time"
+import (
+	"database/sql"
+	"time"
+)
 
 type User struct {
-	ID           int64
-	Username     string
-	Email        string
-	PasswordHash string
-	CreatedAt    time.Time
+	ID                  int64
+	Username            string
+	Email               string
+	PasswordHash        string
+	Role                string
+	DisplayName         string
+	Bio                 string
+	AvatarURL           string
+	Locale              string
+	TimeZone            string
+	EmailVerifiedAt     sql.NullTime
+	VerificationSentAt  sql.NullTime
+	SuspendedAt         sql.NullTime
+	UsernameChangedAt   sql.NullTime
+	DeletionScheduledAt sql.NullTime
+	DeletedAt           sql.NullTime
+	CreatedAt           time.Time
+}
+
+type VerificationToken struct {
+	ID        int64
+	UserID    int64
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
 }
 
 type UserInput struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Username string `json:"username" binding:"required,username"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ChangeUsernameInput struct {
+	Username string `json:"username" binding:"required,username"`
 }
 
+// UpdateProfileInput changes only the fields that are present; an empty
+// string clears a field.
+type UpdateProfileInput struct {
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+	Locale      *string `json:"locale"`
+	TimeZone    *string `json:"time_zone"`
+}
+
+// UserResponse is the public view of an account. Email is only filled in for
+// callers allowed to see it.
 type UserResponse struct {
-	ID       int64  `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	Email       string    `json:"email,omitempty"`
+	Deleted     bool      `json:"deleted,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// MeResponse is what users see about their own account.
+type MeResponse struct {
+	ID                  int64      `json:"id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	EmailVerified       bool       `json:"email_verified"`
+	Role                string     `json:"role"`
+	DisplayName         string     `json:"display_name"`
+	Bio                 string     `json:"bio"`
+	AvatarURL           string     `json:"avatar_url"`
+	Locale              string     `json:"locale"`
+	TimeZone            string     `json:"time_zone"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// PrivacySettings controls who may reach the user and what they may see.
+type PrivacySettings struct {
+	PrivateChats string `json:"private_chats"`
+	GroupInvites string `json:"group_invites"`
+	Presence     string `json:"presence"`
+	ReadReceipts string `json:"read_receipts"`
+}
+
+type UpdatePrivacyInput struct {
+	PrivateChats *string `json:"private_chats"`
+	GroupInvites *string `json:"group_invites"`
+	Presence     *string `json:"presence"`
+	ReadReceipts *string `json:"read_receipts"`
+}
+
+type SearchInput struct {
+	Query  string `form:"q"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+type SearchResponse struct {
+	Users []UserResponse `json:"users"`
+	Total int            `json:"total"`
 }
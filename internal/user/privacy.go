@@ -0,0 +1,60 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Privacy levels decide who a setting applies to. Contacts are the users on
+// the contact list, see the contact package.
+const (
+	PrivacyEveryone = "everyone"
+	PrivacyContacts = "contacts"
+	PrivacyNobody   = "nobody"
+)
+
+func (s *Service) GetPrivacy(ctx context.Context, userID int64) (PrivacySettings, error) {
+	settings, err := s.repo.GetPrivacy(ctx, userID)
+	if err == sql.ErrNoRows {
+		return PrivacySettings{}, ErrUserNotFound
+	}
+	if err != nil {
+		return PrivacySettings{}, fmt.Errorf("db error: %w", err)
+	}
+	return settings, nil
+}
+
+// UpdatePrivacy changes only the settings that are present in the input. The
+// merge happens in the database so concurrent updates keep each other's
+// changes.
+func (s *Service) UpdatePrivacy(ctx context.Context, userID int64, input UpdatePrivacyInput) (PrivacySettings, error) {
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"private_chats", input.PrivateChats},
+		{"group_invites", input.GroupInvites},
+		{"presence", input.Presence},
+		{"read_receipts", input.ReadReceipts},
+	}
+
+	for _, f := range fields {
+		if f.value != nil && !isPrivacyLevel(*f.value) {
+			return PrivacySettings{}, &ProfileError{Field: f.name, Message: "must be one of everyone, contacts, nobody"}
+		}
+	}
+
+	settings, err := s.repo.UpdatePrivacy(ctx, userID, input)
+	if err == sql.ErrNoRows {
+		return PrivacySettings{}, ErrUserNotFound
+	}
+	if err != nil {
+		return PrivacySettings{}, fmt.Errorf("db error: %w", err)
+	}
+	return settings, nil
+}
+
+func isPrivacyLevel(level string) bool {
+	return level == PrivacyEveryone || level == PrivacyContacts || level == PrivacyNobody
+}
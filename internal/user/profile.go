@@ -0,0 +1,215 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	maxDisplayNameLength = 64
+	maxBioLength         = 500
+	maxAvatarURLLength   = 2048
+)
+
+var ErrInvalidProfile = errors.New("invalid profile")
+
+// ProfileError reports which profile field was rejected and why.
+type ProfileError struct {
+	Field   string
+	Message string
+}
+
+func (e *ProfileError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+func (e *ProfileError) Is(target error) bool {
+	return target == ErrInvalidProfile
+}
+
+func (s *Service) GetMe(ctx context.Context, userID int64) (MeResponse, error) {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return MeResponse{}, ErrUserNotFound
+	}
+	if err != nil {
+		return MeResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	return toMeResponse(u), nil
+}
+
+// GetProfile returns the public profile of a user. The email address is only
+// included for the user themselves and for staff. To someone they blocked,
+// users look like they do not exist.
+func (s *Service) GetProfile(ctx context.Context, viewerID int64, viewerRole string, userID int64) (UserResponse, error) {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return UserResponse{}, ErrUserNotFound
+	}
+	if err != nil {
+		return UserResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	staff := HasRole(viewerRole, RoleModerator)
+	if viewerID != u.ID && !staff {
+		blocked, err := s.repo.IsBlockedBy(ctx, u.ID, viewerID)
+		if err != nil {
+			return UserResponse{}, fmt.Errorf("db error: %w", err)
+		}
+		if blocked {
+			return UserResponse{}, ErrUserNotFound
+		}
+	}
+
+	resp := toUserResponse(u)
+	if (viewerID == u.ID || staff) && !u.DeletedAt.Valid {
+		resp.Email = u.Email
+	}
+	return resp, nil
+}
+
+// UpdateProfile changes the fields present in the input. The merge with the
+// stored profile happens in a single UPDATE, so concurrent updates of
+// different fields do not undo each other.
+func (s *Service) UpdateProfile(ctx context.Context, userID int64, input UpdateProfileInput) (MeResponse, error) {
+	fields := []struct {
+		value     *string
+		normalize func(string) (string, error)
+	}{
+		{input.DisplayName, normalizeDisplayName},
+		{input.Bio, normalizeBio},
+		{input.AvatarURL, normalizeAvatarURL},
+		{input.Locale, normalizeLocale},
+		{input.TimeZone, normalizeTimeZone},
+	}
+
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		normalized, err := f.normalize(*f.value)
+		if err != nil {
+			return MeResponse{}, err
+		}
+		*f.value = normalized
+	}
+
+	updated, err := s.repo.UpdateProfile(ctx, userID, input)
+	if err != nil {
+		return MeResponse{}, fmt.Errorf("db error: %w", err)
+	}
+	if !updated {
+		return MeResponse{}, ErrUserNotFound
+	}
+
+	return s.GetMe(ctx, userID)
+}
+
+func normalizeDisplayName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxDisplayNameLength {
+		return "", &ProfileError{"display_name", fmt.Sprintf("must be at most %d characters", maxDisplayNameLength)}
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", &ProfileError{"display_name", "must not contain control characters"}
+	}
+	return name, nil
+}
+
+func normalizeBio(bio string) (string, error) {
+	bio = strings.TrimSpace(bio)
+	if utf8.RuneCountInString(bio) > maxBioLength {
+		return "", &ProfileError{"bio", fmt.Sprintf("must be at most %d characters", maxBioLength)}
+	}
+	if strings.IndexFunc(bio, func(r rune) bool { return unicode.IsControl(r) && r != '\n' }) >= 0 {
+		return "", &ProfileError{"bio", "must not contain control characters"}
+	}
+	return bio, nil
+}
+
+func normalizeAvatarURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	if len(raw) > maxAvatarURLLength {
+		return "", &ProfileError{"avatar_url", fmt.Sprintf("must be at most %d characters", maxAvatarURLLength)}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") || u.User != nil {
+		return "", &ProfileError{"avatar_url", "must be an http or https URL"}
+	}
+	return u.String(), nil
+}
+
+func normalizeLocale(locale string) (string, error) {
+	locale = strings.TrimSpace(locale)
+	if locale == "" {
+		return "", nil
+	}
+
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", &ProfileError{"locale", "must be a BCP 47 language tag such as \"en\" or \"pt-BR\""}
+	}
+	return tag.String(), nil
+}
+
+func normalizeTimeZone(tz string) (string, error) {
+	tz = strings.TrimSpace(tz)
+	if tz == "" {
+		return "", nil
+	}
+
+	if tz == "Local" {
+		return "", &ProfileError{"time_zone", "must be an IANA time zone such as \"Europe/Berlin\""}
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return "", &ProfileError{"time_zone", "must be an IANA time zone such as \"Europe/Berlin\""}
+	}
+	return loc.String(), nil
+}
+
+func toUserResponse(u *User) UserResponse {
+	return UserResponse{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		Deleted:     u.DeletedAt.Valid,
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+func toMeResponse(u *User) MeResponse {
+	resp := MeResponse{
+		ID:            u.ID,
+		Username:      u.Username,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerifiedAt.Valid,
+		Role:          u.Role,
+		DisplayName:   u.DisplayName,
+		Bio:           u.Bio,
+		AvatarURL:     u.AvatarURL,
+		Locale:        u.Locale,
+		TimeZone:      u.TimeZone,
+		CreatedAt:     u.CreatedAt,
+	}
+	if u.DeletionScheduledAt.Valid {
+		resp.DeletionScheduledAt = &u.DeletionScheduledAt.Time
+	}
+	return resp
+}
@@ -3,7 +3,10 @@ package user
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
+
+	"github.com/vladopadikk/go-chat/internal/database"
 )
 
 type Repository struct {
@@ -25,11 +28,25 @@ func (r *Repository) Create(ctx context.Context, username, email, passwordHash s
 	return id, err
 }
 
+// CreateVerified creates an account whose email was verified elsewhere and
+// that has no local password.
+func (r *Repository) CreateVerified(ctx context.Context, exec database.Executor, username, email string, createdAt time.Time) (int64, error) {
+	query := `
+			INSERT INTO users (username, email, password_hash, created_at, email_verified_at)
+			VALUES ($1, $2, '', $3, $3)
+			RETURNING id;
+	`
+	var id int64
+	err := exec.QueryRowContext(ctx, query, username, email, createdAt).Scan(&id)
+	return id, err
+}
+
 func (r *Repository) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-			SELECT id, username, email, password_hash, created_at 
+			SELECT id, username, email, password_hash, role, email_verified_at, verification_sent_at, suspended_at,
+				username_changed_at, deletion_scheduled_at, deleted_at, display_name, bio, avatar_url, locale, time_zone, created_at
 			FROM users
-			WHERE email = $1; 
+			WHERE LOWER(email) = LOWER($1);
 	`
 
 	var user User
@@ -38,6 +55,18 @@ func (r *Repository) GetByEmail(ctx context.Context, email string) (*User, error
 		&user.Username,
 		&user.Email,
 		&user.PasswordHash,
+		&user.Role,
+		&user.EmailVerifiedAt,
+		&user.VerificationSentAt,
+		&user.SuspendedAt,
+		&user.UsernameChangedAt,
+		&user.DeletionScheduledAt,
+		&user.DeletedAt,
+		&user.DisplayName,
+		&user.Bio,
+		&user.AvatarURL,
+		&user.Locale,
+		&user.TimeZone,
 		&user.CreatedAt,
 	)
 
@@ -47,3 +76,323 @@ func (r *Repository) GetByEmail(ctx context.Context, email string) (*User, error
 
 	return &user, err
 }
+
+func (r *Repository) GetByID(ctx context.Context, id int64) (*User, error) {
+	query := `
+			SELECT id, username, email, password_hash, role, email_verified_at, verification_sent_at, suspended_at,
+				username_changed_at, deletion_scheduled_at, deleted_at, display_name, bio, avatar_url, locale, time_zone, created_at
+			FROM users
+			WHERE id = $1;
+	`
+
+	var user User
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Role,
+		&user.EmailVerifiedAt,
+		&user.VerificationSentAt,
+		&user.SuspendedAt,
+		&user.UsernameChangedAt,
+		&user.DeletionScheduledAt,
+		&user.DeletedAt,
+		&user.DisplayName,
+		&user.Bio,
+		&user.AvatarURL,
+		&user.Locale,
+		&user.TimeZone,
+		&user.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *Repository) IsEmailVerified(ctx context.Context, id int64) (bool, error) {
+	query := `
+			SELECT email_verified_at IS NOT NULL
+			FROM users
+			WHERE id = $1;
+	`
+
+	var verified bool
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&verified)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return verified, err
+}
+
+// MarkEmailVerified verifies the user only if the address the token was
+// issued for is still the current one.
+func (r *Repository) MarkEmailVerified(ctx context.Context, exec database.Executor, id int64, email string, verifiedAt time.Time) (bool, error) {
+	query := `
+			UPDATE users
+			SET email_verified_at = $3
+			WHERE id = $1 AND email = $2 AND email_verified_at IS NULL;
+	`
+
+	res, err := exec.ExecContext(ctx, query, id, email, verifiedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ClaimVerificationSend records that a verification email is being sent,
+// unless the previous one went out after notBefore.
+func (r *Repository) ClaimVerificationSend(ctx context.Context, id int64, sentAt, notBefore time.Time) (bool, error) {
+	query := `
+			UPDATE users
+			SET verification_sent_at = $2
+			WHERE id = $1
+				AND email_verified_at IS NULL
+				AND (verification_sent_at IS NULL OR verification_sent_at <= $3);
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id, sentAt, notBefore)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *Repository) CreateVerificationToken(ctx context.Context, token VerificationToken) error {
+	query := `
+			INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4);
+	`
+	_, err := r.db.ExecContext(ctx, query, token.UserID, token.Email, token.TokenHash, token.ExpiresAt)
+	return err
+}
+
+func (r *Repository) GetVerificationTokenForUpdate(ctx context.Context, exec database.Executor, tokenHash string) (VerificationToken, error) {
+	query := `
+			SELECT id, user_id, email, token_hash, expires_at, used_at, created_at
+			FROM email_verification_tokens
+			WHERE token_hash = $1
+			FOR UPDATE;
+	`
+	var token VerificationToken
+	err := exec.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Email,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	return token, err
+}
+
+// MarkVerificationTokensUsed consumes every outstanding verification link of
+// the user once one of them has worked.
+func (r *Repository) MarkVerificationTokensUsed(ctx context.Context, exec database.Executor, userID int64, usedAt time.Time) error {
+	query := `
+			UPDATE email_verification_tokens
+			SET used_at = $2
+			WHERE user_id = $1 AND used_at IS NULL;
+	`
+	_, err := exec.ExecContext(ctx, query, userID, usedAt)
+	return err
+}
+
+func (r *Repository) IsBlockedBy(ctx context.Context, blockerID, blockedID int64) (bool, error) {
+	query := `
+			SELECT EXISTS (
+				SELECT 1
+				FROM user_blocks
+				WHERE blocker_id = $1 AND blocked_id = $2
+			);
+	`
+
+	var blocked bool
+	err := r.db.QueryRowContext(ctx, query, blockerID, blockedID).Scan(&blocked)
+	return blocked, err
+}
+
+func (r *Repository) UsernameExists(ctx context.Context, exec database.Executor, username string) (bool, error) {
+	query := `
+			SELECT EXISTS (
+				SELECT 1
+				FROM users
+				WHERE LOWER(username) = LOWER($1)
+			);
+	`
+
+	var exists bool
+	err := exec.QueryRowContext(ctx, query, username).Scan(&exists)
+	return exists, err
+}
+
+// UpdateUsername renames the user unless the previous change happened after
+// notBefore.
+func (r *Repository) UpdateUsername(ctx context.Context, id int64, username string, changedAt, notBefore time.Time) (bool, error) {
+	query := `
+			UPDATE users
+			SET username = $2, username_changed_at = $3
+			WHERE id = $1
+				AND deleted_at IS NULL
+				AND (username_changed_at IS NULL OR username_changed_at <= $4);
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id, username, changedAt, notBefore)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UpdateProfile sets the fields of the input that are not nil and reports
+// whether the user exists.
+func (r *Repository) UpdateProfile(ctx context.Context, id int64, input UpdateProfileInput) (bool, error) {
+	query := `
+			UPDATE users
+			SET display_name = COALESCE($2, display_name),
+				bio = COALESCE($3, bio),
+				avatar_url = COALESCE($4, avatar_url),
+				locale = COALESCE($5, locale),
+				time_zone = COALESCE($6, time_zone)
+			WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	res, err := r.db.ExecContext(ctx, query, id, input.DisplayName, input.Bio, input.AvatarURL, input.Locale, input.TimeZone)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *Repository) GetPrivacy(ctx context.Context, id int64) (PrivacySettings, error) {
+	query := `
+			SELECT privacy_private_chats, privacy_group_invites, privacy_presence, privacy_read_receipts
+			FROM users
+			WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	var settings PrivacySettings
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&settings.PrivateChats,
+		&settings.GroupInvites,
+		&settings.Presence,
+		&settings.ReadReceipts,
+	)
+	return settings, err
+}
+
+// UpdatePrivacy sets the settings of the input that are not nil and returns
+// the result.
+func (r *Repository) UpdatePrivacy(ctx context.Context, id int64, input UpdatePrivacyInput) (PrivacySettings, error) {
+	query := `
+			UPDATE users
+			SET privacy_private_chats = COALESCE($2, privacy_private_chats),
+				privacy_group_invites = COALESCE($3, privacy_group_invites),
+				privacy_presence = COALESCE($4, privacy_presence),
+				privacy_read_receipts = COALESCE($5, privacy_read_receipts)
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING privacy_private_chats, privacy_group_invites, privacy_presence, privacy_read_receipts;
+	`
+
+	var settings PrivacySettings
+	err := r.db.QueryRowContext(ctx, query, id, input.PrivateChats, input.GroupInvites, input.Presence, input.ReadReceipts).Scan(
+		&settings.PrivateChats,
+		&settings.GroupInvites,
+		&settings.Presence,
+		&settings.ReadReceipts,
+	)
+	return settings, err
+}
+
+// searchFilter selects the users matching a search: $1 is the viewer, $2 the
+// lowercased query and $3 its LIKE prefix pattern.
+const searchFilter = `
+			WHERE deleted_at IS NULL
+				AND suspended_at IS NULL
+				AND id <> $1
+				AND NOT EXISTS (
+					SELECT 1
+					FROM user_blocks b
+					WHERE (b.blocker_id = $1 AND b.blocked_id = users.id)
+						OR (b.blocker_id = users.id AND b.blocked_id = $1)
+				)
+				AND (
+					LOWER(username) LIKE $3
+					OR LOWER(display_name) LIKE $3
+					OR LOWER(username) % $2
+					OR LOWER(display_name) % $2
+				)
+`
+
+// Search finds active users whose username or display name starts with or
+// resembles the query. Prefix matches rank first, then by trigram similarity.
+// Users the viewer blocked or who blocked the viewer are left out. The total
+// counts every match, whatever page was asked for.
+func (r *Repository) Search(ctx context.Context, viewerID int64, query string, limit, offset int) ([]User, int, error) {
+	q := strings.ToLower(query)
+	pattern := escapeLike(q) + "%"
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM users` + searchFilter
+	if err := r.db.QueryRowContext(ctx, countQuery, viewerID, q, pattern).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if total == 0 || offset >= total {
+		return nil, total, nil
+	}
+
+	sqlQuery := `
+			SELECT id, username, display_name, bio, avatar_url, created_at
+			FROM users` + searchFilter + `
+			ORDER BY
+				(LOWER(username) LIKE $3 OR LOWER(display_name) LIKE $3) DESC,
+				GREATEST(similarity(LOWER(username), $2), similarity(LOWER(display_name), $2)) DESC,
+				id
+			LIMIT $4 OFFSET $5;
+	`
+	rows, err := r.db.QueryContext(ctx, sqlQuery, viewerID, q, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.Bio, &u.AvatarURL, &u.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+
+	return users, total, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,30 @@
+package user
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// HasRole reports whether role grants at least the privileges of min.
+// Unknown roles grant nothing.
+func HasRole(role, min string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[min]
+}
+
+// Outranks reports whether role is strictly more privileged than other.
+func Outranks(role, other string) bool {
+	return roleRanks[role] > roleRanks[other]
+}
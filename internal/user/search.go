@@ -0,0 +1,47 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	maxSearchQuery     = 64
+)
+
+var ErrInvalidSearch = errors.New("search query must be between 1 and 64 characters")
+
+// Search looks users up by username or display name for starting chats.
+// Suspended and deleted accounts, and the caller, are never returned.
+func (s *Service) Search(ctx context.Context, viewerID int64, input SearchInput) (SearchResponse, error) {
+	query := strings.TrimSpace(input.Query)
+	if query == "" || utf8.RuneCountInString(query) > maxSearchQuery {
+		return SearchResponse{}, ErrInvalidSearch
+	}
+
+	if input.Limit <= 0 {
+		input.Limit = defaultSearchLimit
+	}
+	if input.Limit > maxSearchLimit {
+		input.Limit = maxSearchLimit
+	}
+	if input.Offset < 0 {
+		input.Offset = 0
+	}
+
+	users, total, err := s.repo.Search(ctx, viewerID, query, input.Limit, input.Offset)
+	if err != nil {
+		return SearchResponse{}, fmt.Errorf("db error: %w", err)
+	}
+
+	resp := SearchResponse{Users: make([]UserResponse, 0, len(users)), Total: total}
+	for i := range users {
+		resp.Users = append(resp.Users, toUserResponse(&users[i]))
+	}
+	return resp, nil
+}
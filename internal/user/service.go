@@ -5,46 +5,99 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vladopadikk/go-chat/internal/config"
+	"github.com/vladopadikk/go-chat/internal/mail"
+	"github.com/vladopadikk/go-chat/internal/password"
+	"github.com/vladopadikk/go-chat/internal/validation"
+)
+
+const (
+	emailUniqueConstraint = "users_email_key"
+	emailUniqueIndex      = "idx_users_email_lower"
 )
 
 var ErrEmailExists = errors.New("email is already registered")
 
 type Service struct {
-	repo *Repository
+	repo   *Repository
+	hasher *password.Hasher
+	policy *password.Policy
+	mailer mail.Mailer
+	cfg    *config.Config
 }
 
-func NewService(repo *Repository) *Service {
-	return &Service{repo}
+func NewService(repo *Repository, hasher *password.Hasher, policy *password.Policy, mailer mail.Mailer, cfg *config.Config) *Service {
+	return &Service{repo, hasher, policy, mailer, cfg}
 }
 
-func (s *Service) Register(ctx context.Context, input UserInput) (UserResponse, error) {
+func (s *Service) Register(ctx context.Context, input UserInput) (MeResponse, error) {
+	input.Email = validation.NormalizeEmail(input.Email)
+
+	if err := s.policy.Validate(input.Password, input.Username, input.Email); err != nil {
+		return MeResponse{}, err
+	}
+
+	if IsReservedUsername(input.Username) {
+		return MeResponse{}, ErrUsernameReserved
+	}
+
 	_, err := s.repo.GetByEmail(ctx, input.Email)
 
 	if err == nil {
-		return UserResponse{}, ErrEmailExists
+		return MeResponse{}, ErrEmailExists
 	}
 
 	if err != sql.ErrNoRows {
-		return UserResponse{}, fmt.Errorf("failed to create user: %w", err)
+		return MeResponse{}, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	taken, err := s.repo.UsernameExists(ctx, s.repo.db, input.Username)
+	if err != nil {
+		return MeResponse{}, fmt.Errorf("failed to create user: %w", err)
+	}
+	if taken {
+		return MeResponse{}, ErrUsernameTaken
 	}
 
-	hashedPass, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	hashedPass, err := s.hasher.Hash(input.Password)
 	if err != nil {
-		return UserResponse{}, err
+		return MeResponse{}, err
 	}
 	createdAt := time.Now()
 
-	id, err := s.repo.Create(ctx, input.Username, input.Email, string(hashedPass), createdAt)
+	// The checks above race with concurrent registrations; the unique
+	// indexes have the final word.
+	id, err := s.repo.Create(ctx, input.Username, input.Email, hashedPass, createdAt)
+	if isUsernameConflict(err) {
+		return MeResponse{}, ErrUsernameTaken
+	}
+	if isEmailConflict(err) {
+		return MeResponse{}, ErrEmailExists
+	}
 	if err != nil {
-		return UserResponse{}, fmt.Errorf("failed to create user: %w", err)
+		return MeResponse{}, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	return UserResponse{
-		ID:       id,
-		Username: input.Username,
-		Email:    input.Email,
-	}, nil
+	u := &User{
+		ID:        id,
+		Username:  input.Username,
+		Email:     input.Email,
+		Role:      RoleUser,
+		CreatedAt: createdAt,
+	}
+	if err := s.sendVerification(ctx, u); err != nil {
+		log.Printf("failed to start email verification for user %d: %v", id, err)
+	}
+
+	return toMeResponse(u), nil
+}
+
+func isEmailConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" &&
+		(pgErr.ConstraintName == emailUniqueIndex || pgErr.ConstraintName == emailUniqueConstraint)
 }
@@ -0,0 +1,166 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/vladopadikk/go-chat/internal/mail"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification link")
+var ErrAlreadyVerified = errors.New("email is already verified")
+var ErrVerificationCooldown = errors.New("verification email was sent recently, try again later")
+var ErrUserNotFound = errors.New("user not found")
+
+// VerifyEmail confirms the address a verification link was issued for. The
+// link keeps answering ErrAlreadyVerified after it has been used.
+func (s *Service) VerifyEmail(ctx context.Context, raw string) error {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	token, err := s.repo.GetVerificationTokenForUpdate(ctx, tx, hashVerificationToken(raw))
+	if err == sql.ErrNoRows {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	now := time.Now()
+
+	if now.After(token.ExpiresAt) {
+		return ErrInvalidVerificationToken
+	}
+
+	if !token.UsedAt.Valid {
+		verified, err := s.repo.MarkEmailVerified(ctx, tx, token.UserID, token.Email, now)
+		if err != nil {
+			return fmt.Errorf("db error: %w", err)
+		}
+		if verified {
+			if err := s.repo.MarkVerificationTokensUsed(ctx, tx, token.UserID, now); err != nil {
+				return fmt.Errorf("db error: %w", err)
+			}
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("commit tx: %w", err)
+			}
+			return nil
+		}
+	}
+
+	u, err := s.repo.GetByID(ctx, token.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvalidVerificationToken
+		}
+		return fmt.Errorf("db error: %w", err)
+	}
+	if u.EmailVerifiedAt.Valid && u.Email == token.Email {
+		return ErrAlreadyVerified
+	}
+	return ErrInvalidVerificationToken
+}
+
+// ResendVerification sends a new verification link, at most once per
+// cooldown period.
+func (s *Service) ResendVerification(ctx context.Context, userID int64) error {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	if u.EmailVerifiedAt.Valid {
+		return ErrAlreadyVerified
+	}
+
+	return s.sendVerification(ctx, u)
+}
+
+func (s *Service) IsEmailVerified(ctx context.Context, userID int64) (bool, error) {
+	verified, err := s.repo.IsEmailVerified(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("db error: %w", err)
+	}
+	return verified, nil
+}
+
+// VerificationCooldown is how long ResendVerification refuses to send
+// another email after the previous one.
+func (s *Service) VerificationCooldown() time.Duration {
+	return s.cfg.EmailVerificationCooldown
+}
+
+func (s *Service) sendVerification(ctx context.Context, u *User) error {
+	now := time.Now()
+
+	claimed, err := s.repo.ClaimVerificationSend(ctx, u.ID, now, now.Add(-s.cfg.EmailVerificationCooldown))
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if !claimed {
+		return ErrVerificationCooldown
+	}
+
+	token, err := newVerificationToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.CreateVerificationToken(ctx, VerificationToken{
+		UserID:    u.ID,
+		Email:     u.Email,
+		TokenHash: hashVerificationToken(token),
+		ExpiresAt: now.Add(s.cfg.EmailVerificationTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+
+	link := s.cfg.AppBaseURL + "/api/verify-email?token=" + url.QueryEscape(token)
+	msg := mail.Message{
+		To:      u.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s\n",
+			u.Username, s.cfg.EmailVerificationTTL, link,
+		),
+	}
+
+	go func() {
+		if err := s.mailer.Send(context.Background(), msg); err != nil {
+			log.Printf("failed to send verification email to user %d: %v", u.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+// newVerificationToken returns a random URL-safe token. Only its hash is
+// stored, like password reset tokens.
+func newVerificationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashVerificationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
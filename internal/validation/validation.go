@@ -0,0 +1,72 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{2,31}$`)
+
+// Register adds the custom rules to the validator gin uses for binding and
+// makes field errors refer to fields by their JSON name.
+func Register() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected binding validator engine")
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	return v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	})
+}
+
+// FieldErrors turns binding validation errors into a map from JSON field name
+// to a readable message. It returns nil for any other kind of error, such as
+// malformed JSON.
+func FieldErrors(err error) map[string]string {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+
+	fields := make(map[string]string, len(errs))
+	for _, fe := range errs {
+		fields[fe.Field()] = message(fe)
+	}
+	return fields
+}
+
+// NormalizeEmail is the canonical form emails are stored and looked up in.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "username":
+		return "must be 3-32 characters of letters, digits, '.', '_' or '-', starting with a letter or digit"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	}
+	return fmt.Sprintf("failed the %q rule", fe.Tag())
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -15,26 +16,58 @@ const (
 	pongWait       = 60 * time.Second
 	pingPeriod     = pongWait * 9 / 10
 	maxMessageSize = 512 * 1024
+
+	// closeAuthExpired is sent when the credentials a connection was opened
+	// with expire without the client re-authenticating.
+	closeAuthExpired = 4001
 )
 
+// TokenVerifier checks an access token sent in-band on an open connection.
+type TokenVerifier interface {
+	Reauthenticate(ctx context.Context, token string) (userID int64, expiresAt time.Time, err error)
+}
+
 type Client struct {
 	hub            *Hub
 	conn           *websocket.Conn
 	send           chan []byte
+	closed         chan struct{}
 	userID         int64
-	chats          []int64
 	messageService *messages.Service
+	verifier       TokenVerifier
+
+	// chats, loading and pending are only touched by the hub goroutine.
+	// Until the client's chats are loaded, membership changes are queued in
+	// pending and replayed over the loaded list.
+	chats   map[int64]bool
+	loading bool
+	pending []membership
+
+	// expiresAt is the unix time in nanoseconds at which the connection's
+	// credentials expire, or 0 if they do not.
+	expiresAt atomic.Int64
+	reauth    chan struct{}
 }
 
-func NewClient(hub *Hub, conn *websocket.Conn, userID int64, chats []int64, messageService *messages.Service) *Client {
-	return &Client{
+// NewClient returns a client that is not subscribed to any chat yet; see
+// Hub.Load.
+func NewClient(hub *Hub, conn *websocket.Conn, userID int64, expiresAt time.Time, messageService *messages.Service, verifier TokenVerifier) *Client {
+	c := &Client{
 		hub:            hub,
 		conn:           conn,
 		userID:         userID,
-		chats:          chats,
+		chats:          make(map[int64]bool),
+		loading:        true,
 		send:           make(chan []byte, 256),
+		closed:         make(chan struct{}),
 		messageService: messageService,
+		verifier:       verifier,
+		reauth:         make(chan struct{}, 1),
 	}
+	if !expiresAt.IsZero() {
+		c.expiresAt.Store(expiresAt.UnixNano())
+	}
+	return c
 }
 
 func (c *Client) ReadPump() {
@@ -62,21 +95,47 @@ func (c *Client) ReadPump() {
 
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(pingPeriod)
+
+	var expiry <-chan time.Time
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	if exp := c.expiresAt.Load(); exp != 0 {
+		timer.Reset(time.Until(time.Unix(0, exp)))
+		expiry = timer.C
+	}
+
 	defer func() {
 		ticker.Stop()
+		timer.Stop()
 		c.conn.Close()
 	}()
 
 	for {
 		select {
-		case msg, ok := <-c.send:
+		case msg := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, nil)
-				return
-			}
 			c.conn.WriteMessage(websocket.TextMessage, msg)
 
+		case <-c.closed:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, nil)
+			return
+
+		case <-c.reauth:
+			timer.Stop()
+			timer.Reset(time.Until(time.Unix(0, c.expiresAt.Load())))
+			expiry = timer.C
+
+		case <-expiry:
+			// A re-authentication may have raced with the timer.
+			if exp := time.Unix(0, c.expiresAt.Load()); time.Now().Before(exp) {
+				timer.Reset(time.Until(exp))
+				continue
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(closeAuthExpired, "authentication expired"))
+			return
+
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -96,6 +155,8 @@ func (c *Client) handleMessage(data []byte) {
 	switch msg.Type {
 	case WSMessageTypeSendMessage:
 		c.handleSendMessage(msg.Payload)
+	case WSMessageTypeAuthenticate:
+		c.handleAuthenticate(msg.Payload)
 	default:
 		c.sendError("unknown message type")
 	}
@@ -128,10 +189,48 @@ func (c *Client) handleSendMessage(payload json.RawMessage) {
 		Payload: payloadBytes,
 	})
 
+	// The message is already stored, so it is delivered even if the blocks
+	// cannot be loaded; REST history still hides it from blockers.
+	exclude, err := c.messageService.HiddenFrom(context.Background(), msg.ChatID, c.userID)
+	if err != nil {
+		log.Printf("failed to load blocks for chat %d, broadcasting to everyone: %v", msg.ChatID, err)
+		exclude = nil
+	}
+
 	c.hub.broadcast <- Broadcast{
-		ChatID: msg.ChatID,
-		Data:   wsMsg,
+		ChatID:  msg.ChatID,
+		Data:    wsMsg,
+		Exclude: exclude,
+	}
+}
+
+// handleAuthenticate extends the life of the connection with a fresh access
+// token for the same user.
+func (c *Client) handleAuthenticate(payload json.RawMessage) {
+	var input AuthenticatePayload
+	if err := json.Unmarshal(payload, &input); err != nil || input.Token == "" {
+		c.sendError("invalid payload")
+		return
 	}
+
+	userID, expiresAt, err := c.verifier.Reauthenticate(context.Background(), input.Token)
+	if err != nil || userID != c.userID {
+		c.sendError("invalid token")
+		return
+	}
+
+	c.expiresAt.Store(expiresAt.UnixNano())
+	select {
+	case c.reauth <- struct{}{}:
+	default:
+	}
+
+	out, _ := json.Marshal(AuthenticatedPayload{ExpiresAt: expiresAt})
+	msg, _ := json.Marshal(WSMessage{
+		Type:    WSMessageTypeAuthenticated,
+		Payload: out,
+	})
+	c.queue(msg)
 }
 
 func (c *Client) sendError(text string) {
@@ -140,5 +239,14 @@ func (c *Client) sendError(text string) {
 		Type:    WSMessageTypeError,
 		Payload: payload,
 	})
-	c.send <- msg
+	c.queue(msg)
+}
+
+// queue hands a reply to WritePump, dropping it if the client cannot keep up
+// or is shutting down.
+func (c *Client) queue(msg []byte) {
+	select {
+	case c.send <- msg:
+	default:
+	}
 }
@@ -6,22 +6,26 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/vladopadikk/go-chat/internal/apikey"
+	"github.com/vladopadikk/go-chat/internal/auth"
 	"github.com/vladopadikk/go-chat/internal/chat"
 	"github.com/vladopadikk/go-chat/internal/messages"
 )
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+	CheckOrigin:  func(r *http.Request) bool { return true },
+	Subprotocols: []string{auth.WSSubprotocolBearer},
 }
 
 type Handler struct {
 	hub            *Hub
 	chatService    *chat.Service
 	messageService *messages.Service
+	verifier       TokenVerifier
 }
 
-func NewHandler(hub *Hub, chatService *chat.Service, msgService *messages.Service) *Handler {
-	return &Handler{hub, chatService, msgService}
+func NewHandler(hub *Hub, chatService *chat.Service, msgService *messages.Service, verifier TokenVerifier) *Handler {
+	return &Handler{hub, chatService, msgService, verifier}
 }
 
 func (h *Handler) ServeWS(ctx *gin.Context) {
@@ -36,37 +40,36 @@ func (h *Handler) ServeWS(ctx *gin.Context) {
 		return
 	}
 
-	chatList, err := h.chatService.GetChatsList(ctx.Request.Context(), userID)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get chats"})
-		return
-	}
-
-	var chatIDs []int64
-	for _, ch := range chatList.Chats {
-		chatIDs = append(chatIDs, ch.ID)
-	}
-
-	if len(chatIDs) == 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "user has no chats"})
-		return
-	}
-
 	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
 	if err != nil {
 		log.Printf("Failed to upgrade connection: %v", err)
 		return
 	}
 
-	client := NewClient(h.hub, conn, userID, chatIDs, h.messageService)
+	// The client is registered before its chats are read so that joins and
+	// leaves committed meanwhile still reach it.
+	client := NewClient(h.hub, conn, userID, ctx.GetTime("authExpiresAt"), h.messageService, h.verifier)
 	h.hub.register <- client
 
 	go client.WritePump()
 	go client.ReadPump()
 
+	chatList, err := h.chatService.GetChatsList(ctx.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to get chats: userID=%d: %v", userID, err)
+		h.hub.unregister <- client
+		return
+	}
+
+	chatIDs := make([]int64, 0, len(chatList.Chats))
+	for _, ch := range chatList.Chats {
+		chatIDs = append(chatIDs, ch.ID)
+	}
+	h.hub.Load(client, chatIDs)
+
 	log.Printf("WebSocket connection established: userID=%d", userID)
 }
 
 func RegisterRoutes(r *gin.RouterGroup, h *Handler) {
-	r.GET("/ws", h.ServeWS)
+	apikey.Handle(r, http.MethodGet, "/ws", apikey.ScopeMessagesRead, h.ServeWS)
 }
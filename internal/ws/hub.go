@@ -1,59 +1,259 @@
 package ws
 
+import (
+	"encoding/json"
+	"log"
+	"slices"
+)
+
+// Broadcast delivers Data to every connection subscribed to the chat except
+// those of the users in Exclude.
 type Broadcast struct {
-	ChatID int64
+	ChatID  int64
+	Data    []byte
+	Exclude []int64
+}
+
+// direct is an event addressed to every connection of a single user rather
+// than to a chat.
+type direct struct {
+	UserID int64
 	Data   []byte
 }
 
+// membership adds a user's open connections to a chat or removes them from
+// it.
+type membership struct {
+	userID int64
+	chatID int64
+	join   bool
+}
+
+// loaded hands a registered client the chats it was a member of when they
+// were read from the database.
+type loaded struct {
+	client  *Client
+	chatIDs []int64
+}
+
+// PresenceListener is told when a user's first connection opens and when
+// their last one closes. It is called from the hub goroutine and must not
+// block.
+type PresenceListener interface {
+	UserOnline(userID int64)
+	UserOffline(userID int64)
+}
+
 type Hub struct {
 	clients    map[int64]map[*Client]bool
+	users      map[int64]map[*Client]bool
 	register   chan *Client
 	unregister chan *Client
 	broadcast  chan Broadcast
+	direct     chan direct
+	membership chan membership
+	loaded     chan loaded
+	disconnect chan int64
+	presence   PresenceListener
 }
 
 func NewHub() *Hub {
 	return &Hub{
 		clients:    make(map[int64]map[*Client]bool),
+		users:      make(map[int64]map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		broadcast:  make(chan Broadcast),
+		direct:     make(chan direct),
+		membership: make(chan membership),
+		loaded:     make(chan loaded),
+		disconnect: make(chan int64),
 	}
 }
 
+// SetPresenceListener must be called before Run.
+func (h *Hub) SetPresenceListener(listener PresenceListener) {
+	h.presence = listener
+}
+
+// DisconnectUser closes every connection of the user, e.g. after the account
+// was suspended or signed out by an administrator.
+func (h *Hub) DisconnectUser(userID int64) {
+	h.disconnect <- userID
+}
+
+// Subscribe makes the user's open connections receive the chat's messages,
+// e.g. after they joined it.
+func (h *Hub) Subscribe(userID, chatID int64) {
+	h.membership <- membership{userID: userID, chatID: chatID, join: true}
+}
+
+// Unsubscribe stops the chat's messages reaching the user's open
+// connections, e.g. after they left or were removed.
+func (h *Hub) Unsubscribe(userID, chatID int64) {
+	h.membership <- membership{userID: userID, chatID: chatID, join: false}
+}
+
+// Load subscribes a registered client to its chats. Clients are registered
+// before their chats are read so that no Subscribe or Unsubscribe made in
+// between is lost: those are queued and applied on top of chatIDs.
+func (h *Hub) Load(client *Client, chatIDs []int64) {
+	h.loaded <- loaded{client: client, chatIDs: chatIDs}
+}
+
+// NotifyUser sends an event to every open connection of the user. Users
+// without a connection simply miss it.
+func (h *Hub) NotifyUser(userID int64, eventType string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("failed to marshal %s event: %v", eventType, err)
+		return
+	}
+
+	msg, err := json.Marshal(WSMessage{Type: eventType, Payload: data})
+	if err != nil {
+		log.Printf("failed to marshal %s event: %v", eventType, err)
+		return
+	}
+
+	h.direct <- direct{UserID: userID, Data: msg}
+}
+
+// NotifyChat sends an event to every open connection subscribed to the chat.
+func (h *Hub) NotifyChat(chatID int64, eventType string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("failed to marshal %s event: %v", eventType, err)
+		return
+	}
+
+	msg, err := json.Marshal(WSMessage{Type: eventType, Payload: data})
+	if err != nil {
+		log.Printf("failed to marshal %s event: %v", eventType, err)
+		return
+	}
+
+	h.broadcast <- Broadcast{ChatID: chatID, Data: msg}
+}
+
 func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.register:
-			for _, chatID := range client.chats {
-				if h.clients[chatID] == nil {
-					h.clients[chatID] = make(map[*Client]bool)
+			if h.users[client.userID] == nil {
+				h.users[client.userID] = make(map[*Client]bool)
+				if h.presence != nil {
+					h.presence.UserOnline(client.userID)
 				}
-				h.clients[chatID][client] = true
 			}
+			h.users[client.userID][client] = true
 
 		case client := <-h.unregister:
-			for _, chatID := range client.chats {
-				if clients, ok := h.clients[chatID]; ok {
-					delete(clients, client)
-					if len(clients) == 0 {
-						delete(h.clients, chatID)
-					}
+			h.removeClient(client)
+
+		case m := <-h.membership:
+			for client := range h.users[m.userID] {
+				if client.loading {
+					client.pending = append(client.pending, m)
+					continue
+				}
+				if m.join {
+					client.chats[m.chatID] = true
+					h.subscribe(client, m.chatID)
+				} else {
+					delete(client.chats, m.chatID)
+					h.unsubscribe(client, m.chatID)
+				}
+			}
+
+		case l := <-h.loaded:
+			client := l.client
+			if !h.users[client.userID][client] {
+				continue
+			}
+			for _, chatID := range l.chatIDs {
+				client.chats[chatID] = true
+			}
+			for _, m := range client.pending {
+				if m.join {
+					client.chats[m.chatID] = true
+				} else {
+					delete(client.chats, m.chatID)
 				}
 			}
-			close(client.send)
+			client.loading = false
+			client.pending = nil
+			for chatID := range client.chats {
+				h.subscribe(client, chatID)
+			}
+
+		case userID := <-h.disconnect:
+			for client := range h.users[userID] {
+				h.removeClient(client)
+			}
 
 		case msg := <-h.broadcast:
 			if clients, ok := h.clients[msg.ChatID]; ok {
 				for c := range clients {
+					if slices.Contains(msg.Exclude, c.userID) {
+						continue
+					}
 					select {
 					case c.send <- msg.Data:
 					default:
-						close(c.send)
-						delete(clients, c)
+						h.removeClient(c)
 					}
 				}
 			}
+
+		case msg := <-h.direct:
+			for c := range h.users[msg.UserID] {
+				select {
+				case c.send <- msg.Data:
+				default:
+					h.removeClient(c)
+				}
+			}
+		}
+	}
+}
+
+// removeClient drops the client from every index and closes its closed
+// channel, which makes WritePump close the connection. Clients that were
+// already removed are ignored so the channel is closed exactly once.
+func (h *Hub) removeClient(client *Client) {
+	conns, ok := h.users[client.userID]
+	if !ok || !conns[client] {
+		return
+	}
+
+	delete(conns, client)
+	if len(conns) == 0 {
+		delete(h.users, client.userID)
+		if h.presence != nil {
+			h.presence.UserOffline(client.userID)
+		}
+	}
+
+	for chatID := range client.chats {
+		h.unsubscribe(client, chatID)
+	}
+
+	close(client.closed)
+}
+
+func (h *Hub) subscribe(client *Client, chatID int64) {
+	if h.clients[chatID] == nil {
+		h.clients[chatID] = make(map[*Client]bool)
+	}
+	h.clients[chatID][client] = true
+}
+
+func (h *Hub) unsubscribe(client *Client, chatID int64) {
+	if clients, ok := h.clients[chatID]; ok {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(h.clients, chatID)
 		}
 	}
 }
@@ -0,0 +1,71 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestClient(hub *Hub, userID int64) *Client {
+	return NewClient(hub, nil, userID, time.Time{}, nil, nil)
+}
+
+// received reports whether the client was sent anything within a short wait.
+func received(c *Client) bool {
+	select {
+	case <-c.send:
+		return true
+	case <-time.After(50 * time.Millisecond):
+		return false
+	}
+}
+
+func TestHubLoadReplaysMembershipChanges(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	client := newTestClient(hub, 1)
+	hub.register <- client
+
+	// Changes made while the client's chats were being read.
+	hub.Unsubscribe(1, 10)
+	hub.Subscribe(1, 20)
+
+	// The list was read before the changes were committed.
+	hub.Load(client, []int64{10, 30})
+
+	tests := []struct {
+		chatID int64
+		want   bool
+	}{
+		{10, false},
+		{20, true},
+		{30, true},
+	}
+	for _, tt := range tests {
+		hub.NotifyChat(tt.chatID, "test", nil)
+		if got := received(client); got != tt.want {
+			t.Errorf("chat %d: received = %v, want %v", tt.chatID, got, tt.want)
+		}
+	}
+}
+
+func TestHubIgnoresBroadcastsBeforeLoad(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	client := newTestClient(hub, 1)
+	hub.register <- client
+	hub.Subscribe(1, 10)
+
+	hub.NotifyChat(10, "test", nil)
+	if received(client) {
+		t.Fatal("client received a chat event before its chats were loaded")
+	}
+
+	hub.Load(client, nil)
+
+	hub.NotifyChat(10, "test", nil)
+	if !received(client) {
+		t.Fatal("client did not receive a chat event after loading")
+	}
+}
@@ -6,9 +6,11 @@ import (
 )
 
 const (
-	WSMessageTypeSendMessage = "send_message"
-	WSMessageTypeNewMessage  = "new_message"
-	WSMessageTypeError       = "error"
+	WSMessageTypeSendMessage   = "send_message"
+	WSMessageTypeAuthenticate  = "authenticate"
+	WSMessageTypeNewMessage    = "new_message"
+	WSMessageTypeAuthenticated = "authenticated"
+	WSMessageTypeError         = "error"
 )
 
 type WSMessage struct {
@@ -29,6 +31,14 @@ type NewMessagePayload struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+type AuthenticatePayload struct {
+	Token string `json:"token"`
+}
+
+type AuthenticatedPayload struct {
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 type ErrorPayload struct {
 	Message string `json:"message"`
 }
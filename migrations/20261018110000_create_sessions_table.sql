@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP,
+
+    CONSTRAINT fk_sessions_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sessions_user_id
+    ON sessions (user_id);
+
+-- Refresh tokens issued before sessions existed cannot be attributed to one,
+-- so their owners have to log in again.
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+    ADD COLUMN session_id BIGINT NOT NULL,
+    ADD CONSTRAINT fk_refresh_tokens_session
+        FOREIGN KEY (session_id)
+        REFERENCES sessions(id)
+        ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE refresh_tokens DROP COLUMN session_id;
+DROP TABLE sessions;
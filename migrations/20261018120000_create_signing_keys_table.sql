@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE signing_keys (
+    kid VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    CHECK (algorithm IN ('RS256', 'EdDSA'))
+);
+
+-- +goose Down
+DROP TABLE signing_keys;
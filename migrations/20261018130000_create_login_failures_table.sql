@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE login_failures (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+CREATE TABLE account_lockouts (
+    id BIGSERIAL PRIMARY KEY,
+    scope VARCHAR(20) NOT NULL,
+    user_id BIGINT,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    failures INT NOT NULL,
+    locked_until TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (scope IN ('account', 'ip')),
+
+    CONSTRAINT fk_account_lockouts_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX idx_account_lockouts_created_at
+    ON account_lockouts (created_at);
+
+-- +goose Down
+DROP TABLE account_lockouts;
+DROP TABLE login_failures;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN email_verified_at TIMESTAMP,
+    ADD COLUMN verification_sent_at TIMESTAMP;
+
+-- Accounts that existed before verification was introduced stay usable.
+UPDATE users SET email_verified_at = created_at;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN verification_sent_at,
+    DROP COLUMN email_verified_at;
@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE oidc_login_states (
+    state VARCHAR(64) PRIMARY KEY,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE user_identities (
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id BIGINT NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (issuer, subject),
+
+    CONSTRAINT fk_user_identities_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_identities_user_id
+    ON user_identities (user_id);
+
+-- +goose Down
+DROP TABLE user_identities;
+DROP TABLE oidc_login_states;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Lower-case stored emails where that does not collide with another account.
+-- Accounts that differ only in case must be merged by hand; the unique index
+-- below fails until they are.
+UPDATE users u
+SET email = LOWER(TRIM(u.email))
+WHERE u.email <> LOWER(TRIM(u.email))
+    AND NOT EXISTS (
+        SELECT 1 FROM users o
+        WHERE o.id <> u.id AND LOWER(TRIM(o.email)) = LOWER(TRIM(u.email))
+    );
+
+CREATE UNIQUE INDEX idx_users_email_lower
+    ON users (LOWER(email));
+
+-- +goose Down
+DROP INDEX idx_users_email_lower;
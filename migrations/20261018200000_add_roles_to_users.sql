@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user',
+    ADD COLUMN suspended_at TIMESTAMP,
+    ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE INDEX idx_users_role
+    ON users (role)
+    WHERE role <> 'user';
+
+-- +goose Down
+DROP INDEX idx_users_role;
+
+ALTER TABLE users
+    DROP CONSTRAINT users_role_check,
+    DROP COLUMN suspended_at,
+    DROP COLUMN role;
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE ws_tickets (
+    ticket_hash VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    session_id BIGINT NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT '',
+    auth_expires_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_ws_tickets_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_ws_tickets_session
+        FOREIGN KEY (session_id)
+        REFERENCES sessions(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_ws_tickets_expires_at
+    ON ws_tickets (expires_at);
+
+-- +goose Down
+DROP TABLE ws_tickets;
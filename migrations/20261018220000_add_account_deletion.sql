@@ -0,0 +1,31 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN deletion_scheduled_at TIMESTAMP,
+    ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_users_deletion_scheduled_at
+    ON users (deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
+
+-- Accounts are anonymized instead of deleted, so a stray DELETE must not take
+-- other people's chat history with it.
+ALTER TABLE messages
+    DROP CONSTRAINT fk_messages_sender,
+    ADD CONSTRAINT fk_messages_sender
+        FOREIGN KEY (sender_id)
+        REFERENCES users(id)
+        ON DELETE RESTRICT;
+
+-- +goose Down
+ALTER TABLE messages
+    DROP CONSTRAINT fk_messages_sender,
+    ADD CONSTRAINT fk_messages_sender
+        FOREIGN KEY (sender_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE;
+
+DROP INDEX idx_users_deletion_scheduled_at;
+
+ALTER TABLE users
+    DROP COLUMN deleted_at,
+    DROP COLUMN deletion_scheduled_at;
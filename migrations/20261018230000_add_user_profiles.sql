@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN display_name VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN bio VARCHAR(500) NOT NULL DEFAULT '',
+    ADD COLUMN avatar_url VARCHAR(2048) NOT NULL DEFAULT '',
+    ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT '',
+    ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN time_zone,
+    DROP COLUMN locale,
+    DROP COLUMN avatar_url,
+    DROP COLUMN bio,
+    DROP COLUMN display_name;
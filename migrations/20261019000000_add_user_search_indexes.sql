@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Trigram indexes serve both the prefix (LIKE 'abc%') and the fuzzy
+-- (similarity) part of the directory search.
+CREATE INDEX idx_users_username_trgm
+    ON users USING GIN (LOWER(username) gin_trgm_ops);
+
+CREATE INDEX idx_users_display_name_trgm
+    ON users USING GIN (LOWER(display_name) gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX idx_users_display_name_trgm;
+DROP INDEX idx_users_username_trgm;
@@ -0,0 +1,54 @@
+-- +goose Up
+UPDATE users
+SET username = 'deleted-' || id
+WHERE deleted_at IS NOT NULL;
+
+-- Bring legacy names in line with the username rules: 3 to 32 letters,
+-- digits, dots, underscores and hyphens, starting with a letter or digit.
+-- Names with too little left over become user-<id>.
+UPDATE users u
+SET username = CASE
+        WHEN LENGTH(c.clean) >= 3 THEN c.clean
+        ELSE 'user-' || u.id
+    END
+FROM (
+    SELECT id, LEFT(REGEXP_REPLACE(REGEXP_REPLACE(username, '[^A-Za-z0-9._-]', '', 'g'), '^[._-]+', ''), 32) AS clean
+    FROM users
+) c
+WHERE c.id = u.id
+    AND u.username !~ '^[A-Za-z0-9][A-Za-z0-9._-]{2,31}$';
+
+-- Keep the oldest account's name and suffix later duplicates with their id.
+-- A suffixed name can itself clash with an existing one, so this repeats,
+-- adding an underscore to the suffix each round, until every name is unique.
+-- +goose StatementBegin
+DO $$
+DECLARE
+    attempt INT := 0;
+BEGIN
+    LOOP
+        UPDATE users u
+        SET username = LEFT(u.username, 31 - LENGTH(u.id::text) - attempt) || '-' || u.id || REPEAT('_', attempt)
+        FROM (
+            SELECT id, ROW_NUMBER() OVER (PARTITION BY LOWER(username) ORDER BY id) AS rn
+            FROM users
+        ) d
+        WHERE d.id = u.id AND d.rn > 1;
+
+        EXIT WHEN NOT FOUND;
+        attempt := attempt + 1;
+    END LOOP;
+END $$;
+-- +goose StatementEnd
+
+ALTER TABLE users
+    ADD COLUMN username_changed_at TIMESTAMP;
+
+CREATE UNIQUE INDEX idx_users_username_lower
+    ON users (LOWER(username));
+
+-- +goose Down
+DROP INDEX idx_users_username_lower;
+
+ALTER TABLE users
+    DROP COLUMN username_changed_at;
@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE user_blocks (
+    blocker_id BIGINT NOT NULL,
+    blocked_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (blocker_id, blocked_id),
+
+    CONSTRAINT fk_user_blocks_blocker
+        FOREIGN KEY (blocker_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_user_blocks_blocked
+        FOREIGN KEY (blocked_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT user_blocks_self_check CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX idx_user_blocks_blocked_id
+    ON user_blocks (blocked_id);
+
+-- Messages in a private chat from someone the recipient has blocked are
+-- kept for the sender only.
+ALTER TABLE messages
+    ADD COLUMN withheld BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE messages
+    DROP COLUMN withheld;
+
+DROP TABLE user_blocks;
@@ -0,0 +1,55 @@
+-- +goose Up
+-- Every contact relationship is stored once for each side.
+CREATE TABLE contacts (
+    user_id    BIGINT NOT NULL,
+    contact_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, contact_id),
+
+    CONSTRAINT fk_contacts_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_contacts_contact
+        FOREIGN KEY (contact_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE contact_requests (
+    id           BIGSERIAL PRIMARY KEY,
+    sender_id    BIGINT NOT NULL,
+    recipient_id BIGINT NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT uq_contact_requests_pair UNIQUE (sender_id, recipient_id),
+
+    CONSTRAINT fk_contact_requests_sender
+        FOREIGN KEY (sender_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_contact_requests_recipient
+        FOREIGN KEY (recipient_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT contact_requests_self_check CHECK (sender_id <> recipient_id)
+);
+
+CREATE INDEX idx_contact_requests_recipient_id
+    ON contact_requests (recipient_id);
+
+ALTER TABLE users
+    ADD COLUMN privacy_private_chats VARCHAR(16) NOT NULL DEFAULT 'everyone',
+    ADD CONSTRAINT users_privacy_private_chats_check CHECK (privacy_private_chats IN ('everyone', 'contacts'));
+
+-- +goose Down
+ALTER TABLE users
+    DROP CONSTRAINT users_privacy_private_chats_check,
+    DROP COLUMN privacy_private_chats;
+
+DROP TABLE contact_requests;
+DROP TABLE contacts;
@@ -0,0 +1,55 @@
+-- +goose Up
+ALTER TABLE users
+    DROP CONSTRAINT users_privacy_private_chats_check,
+    ADD CONSTRAINT users_privacy_private_chats_check CHECK (privacy_private_chats IN ('everyone', 'contacts', 'nobody')),
+    ADD COLUMN privacy_group_invites VARCHAR(16) NOT NULL DEFAULT 'everyone',
+    ADD CONSTRAINT users_privacy_group_invites_check CHECK (privacy_group_invites IN ('everyone', 'contacts', 'nobody')),
+    ADD COLUMN privacy_presence VARCHAR(16) NOT NULL DEFAULT 'everyone',
+    ADD CONSTRAINT users_privacy_presence_check CHECK (privacy_presence IN ('everyone', 'contacts', 'nobody')),
+    ADD COLUMN privacy_read_receipts VARCHAR(16) NOT NULL DEFAULT 'everyone',
+    ADD CONSTRAINT users_privacy_read_receipts_check CHECK (privacy_read_receipts IN ('everyone', 'contacts', 'nobody'));
+
+-- Users whose settings do not let the inviter add them to a group directly
+-- get an invitation they can accept or decline.
+CREATE TABLE chat_invitations (
+    id         BIGSERIAL PRIMARY KEY,
+    chat_id    BIGINT NOT NULL,
+    inviter_id BIGINT NOT NULL,
+    invitee_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT uq_chat_invitations_chat_invitee UNIQUE (chat_id, invitee_id),
+
+    CONSTRAINT fk_chat_invitations_chat
+        FOREIGN KEY (chat_id)
+        REFERENCES chats(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_chat_invitations_inviter
+        FOREIGN KEY (inviter_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_chat_invitations_invitee
+        FOREIGN KEY (invitee_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_chat_invitations_invitee_id
+    ON chat_invitations (invitee_id);
+
+-- +goose Down
+DROP TABLE chat_invitations;
+
+UPDATE users SET privacy_private_chats = 'contacts' WHERE privacy_private_chats = 'nobody';
+
+ALTER TABLE users
+    DROP CONSTRAINT users_privacy_read_receipts_check,
+    DROP COLUMN privacy_read_receipts,
+    DROP CONSTRAINT users_privacy_presence_check,
+    DROP COLUMN privacy_presence,
+    DROP CONSTRAINT users_privacy_group_invites_check,
+    DROP COLUMN privacy_group_invites,
+    DROP CONSTRAINT users_privacy_private_chats_check,
+    ADD CONSTRAINT users_privacy_private_chats_check CHECK (privacy_private_chats IN ('everyone', 'contacts'));
@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE chat_members
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'member',
+    ADD CONSTRAINT chat_members_role_check CHECK (role IN ('owner', 'admin', 'member'));
+
+-- Groups created before roles existed get their earliest member as owner.
+UPDATE chat_members cm
+SET role = 'owner'
+FROM (
+    SELECT DISTINCT ON (m.chat_id) m.chat_id, m.user_id
+    FROM chat_members m
+    JOIN chats c ON c.id = m.chat_id AND c.type = 'group'
+    ORDER BY m.chat_id, m.joined_at, m.user_id
+) first
+WHERE cm.chat_id = first.chat_id AND cm.user_id = first.user_id;
+
+CREATE UNIQUE INDEX idx_chat_members_owner
+    ON chat_members (chat_id)
+    WHERE role = 'owner';
+
+-- +goose Down
+DROP INDEX idx_chat_members_owner;
+
+ALTER TABLE chat_members
+    DROP CONSTRAINT chat_members_role_check,
+    DROP COLUMN role;
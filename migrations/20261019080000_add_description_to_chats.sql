@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE chats
+    ADD COLUMN description TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE chats
+    DROP COLUMN description;
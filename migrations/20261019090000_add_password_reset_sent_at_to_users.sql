@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN password_reset_sent_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN password_reset_sent_at;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE chat_members
+    ADD COLUMN last_read_message_id BIGINT,
+    ADD COLUMN last_read_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE chat_members
+    DROP COLUMN last_read_at,
+    DROP COLUMN last_read_message_id;